@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const amqpPublishTimeout = 30 * time.Second
+
+// defaultAMQPHeaderMapping forwards every message header as an AMQP header
+var defaultAMQPHeaderMapping = headerMapping{PassThroughAll: true}
+
+// amqpProducerConfig holds the settings specific to the amqp producer
+type amqpProducerConfig struct {
+	exchange      string
+	routingKey    string
+	headerMapping string
+}
+
+// amqpMessageProducer publishes the messages to a RabbitMQ exchange, with a routing key resolved from each message.
+// Messages are published as mandatory and persistent, and are only reported as sent once the broker confirmed them,
+// so that a message the broker couldn't route or store fails instead of being dropped.
+// The connection is opened again on the next message after it was lost.
+type amqpMessageProducer struct {
+	url        string
+	exchange   string
+	routingKey *urlTemplate
+	mapping    *headerMapping
+
+	lock    sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+	returns chan amqp.Return
+}
+
+func newAMQPMessageProducer(url string, config amqpProducerConfig) (*amqpMessageProducer, error) {
+	if config.exchange == "" {
+		return nil, errors.New("the AMQP exchange is required")
+	}
+	routingKey, err := parseURLTemplate(config.routingKey)
+	if err != nil {
+		return nil, err
+	}
+	mapping := &defaultAMQPHeaderMapping
+	if strings.TrimSpace(config.headerMapping) != "" {
+		if mapping, err = parseHeaderMapping(config.headerMapping); err != nil {
+			return nil, err
+		}
+	}
+
+	p := &amqpMessageProducer{url: url, exchange: config.exchange, routingKey: routingKey, mapping: mapping}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.connect(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// connect opens the connection and a publishing channel in confirm mode, unless they are still open
+func (p *amqpMessageProducer) connect() error {
+	if p.channel != nil && !p.channel.IsClosed() {
+		return nil
+	}
+	if p.conn == nil || p.conn.IsClosed() {
+		conn, err := amqp.Dial(p.url)
+		if err != nil {
+			return err
+		}
+		p.conn = conn
+	}
+	channel, err := p.conn.Channel()
+	if err != nil {
+		return err
+	}
+	if err := channel.Confirm(false); err != nil {
+		channel.Close()
+		return err
+	}
+	p.returns = channel.NotifyReturn(make(chan amqp.Return, 1))
+	p.channel = channel
+	return nil
+}
+
+func (p *amqpMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	tid := messageHeaders(message.Headers).Get("X-Request-Id")
+	if err := p.publish(message); err != nil {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %v", tid, err)
+	}
+	return nil
+}
+
+// publish sends one message at a time, so that a returned message is the one waiting for its confirmation.
+// The broker returns an unroutable message before confirming it.
+func (p *amqpMessageProducer) publish(message queueProducer.Message) error {
+	routingKey, err := p.routingKey.expandRaw(message.Headers, message.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't resolve the routing key: %v", err)
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.connect(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), amqpPublishTimeout)
+	defer cancel()
+	confirmation, err := p.channel.PublishWithDeferredConfirmWithContext(ctx, p.exchange, routingKey, true, false, newAMQPPublishing(message, p.mapping))
+	if err != nil {
+		return err
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		// a late confirmation or return would be taken for the next message's
+		p.channel.Close()
+		return err
+	}
+	select {
+	case returned := <-p.returns:
+		return fmt.Errorf("the message was returned by the broker with routing key %s: %s", returned.RoutingKey, returned.ReplyText)
+	default:
+	}
+	if !acked {
+		return errors.New("the message was rejected by the broker")
+	}
+	return nil
+}
+
+// ConnectivityCheck checks the exchange exists on a channel of its own, as a failed check closes the channel
+func (p *amqpMessageProducer) ConnectivityCheck() (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if err := p.connect(); err != nil {
+		return "Forwarding messages is broken. Couldn't connect to the AMQP broker", err
+	}
+	channel, err := p.conn.Channel()
+	if err != nil {
+		return "Forwarding messages is broken. Couldn't open an AMQP channel", err
+	}
+	defer channel.Close()
+	if err := channel.ExchangeDeclarePassive(p.exchange, amqp.ExchangeTopic, true, false, false, false, nil); err != nil {
+		return "Forwarding messages is broken. Couldn't find the AMQP exchange " + p.exchange, err
+	}
+	return "Connectivity to the AMQP broker is OK", nil
+}
+
+// Close closes the connection, the pending confirmations have been waited for by then
+func (p *amqpMessageProducer) Close() error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.conn == nil || p.conn.IsClosed() {
+		return nil
+	}
+	return p.conn.Close()
+}
+
+// newAMQPPublishing maps the message headers to AMQP headers, and sets the matching AMQP properties
+// from the Message-Id, X-Request-Id, Content-Type and Message-Timestamp headers
+func newAMQPPublishing(message queueProducer.Message, mapping *headerMapping) amqp.Publishing {
+	headers := messageHeaders(message.Headers)
+	publishing := amqp.Publishing{
+		Headers:       amqp.Table{},
+		ContentType:   headers.Get("Content-Type"),
+		MessageId:     headers.Get("Message-Id"),
+		CorrelationId: headers.Get("X-Request-Id"),
+		DeliveryMode:  amqp.Persistent,
+		Body:          []byte(message.Body),
+	}
+	if timestamp, err := time.Parse(messageTimestampFormat, headers.Get(messageTimestampHeader)); err == nil {
+		publishing.Timestamp = timestamp
+	}
+	for name, value := range mapping.apply(message.Headers) {
+		publishing.Headers[name] = value
+	}
+	return publishing
+}
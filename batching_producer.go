@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+// asyncProducer is implemented by the producers which can take a message without waiting for its outcome
+type asyncProducer interface {
+	sendAsync(message queueProducer.Message) <-chan error
+}
+
+// batchingConfig bounds the batches: a batch is sent as soon as it reaches maxMessages or maxBytes,
+// or linger after its first message
+type batchingConfig struct {
+	maxMessages int
+	maxBytes    int
+	linger      time.Duration
+}
+
+type pendingMessage struct {
+	message queueProducer.Message
+	outcome chan error
+}
+
+// batchingProducer accumulates messages and sends them to the destination in a single request.
+// The outcome of every message is reported separately, so that the forwarder handles each one as if it was sent alone.
+type batchingProducer struct {
+	producer interface {
+		queueProducer.MessageProducer
+		batchProducer
+	}
+	config batchingConfig
+	queue  chan pendingMessage
+
+	// closed is guarded by mu, which sendAsync holds for reading while it queues a message
+	mu      sync.RWMutex
+	closed  bool
+	stopped chan struct{}
+}
+
+func newBatchingProducer(p queueProducer.MessageProducer, config batchingConfig) (*batchingProducer, error) {
+	batcher, ok := p.(interface {
+		queueProducer.MessageProducer
+		batchProducer
+	})
+	if !ok {
+		return nil, errors.New("the producer type doesn't support batching")
+	}
+	if config.maxMessages < 1 || config.maxBytes < 1 || config.linger <= 0 {
+		return nil, errors.New("the batch size, bytes and linger time must be positive")
+	}
+	b := &batchingProducer{producer: batcher, config: config, queue: make(chan pendingMessage, config.maxMessages), stopped: make(chan struct{})}
+	go b.run()
+	return b, nil
+}
+
+func (b *batchingProducer) sendAsync(message queueProducer.Message) <-chan error {
+	outcome := make(chan error, 1)
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		outcome <- errors.New("the batching producer is closed")
+		return outcome
+	}
+	b.queue <- pendingMessage{message: message, outcome: outcome}
+	return outcome
+}
+
+func (b *batchingProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	return <-b.sendAsync(message)
+}
+
+func (b *batchingProducer) ConnectivityCheck() (string, error) {
+	return b.producer.ConnectivityCheck()
+}
+
+// Close publishes the batch being filled up, then closes the wrapped producer when it holds connections.
+// The messages handed over afterwards fail.
+func (b *batchingProducer) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	close(b.queue)
+	b.mu.Unlock()
+
+	<-b.stopped
+	if closer, ok := b.producer.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+func (b *batchingProducer) run() {
+	defer close(b.stopped)
+	var batch []pendingMessage
+	var size int
+	timer := time.NewTimer(b.config.linger)
+	timer.Stop()
+
+	flush := func() {
+		if !timer.Stop() {
+			// drain a linger which expired while the batch was being filled up
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		if len(batch) > 0 {
+			b.send(batch)
+		}
+		batch, size = nil, 0
+	}
+
+	for {
+		select {
+		case pending, open := <-b.queue:
+			if !open {
+				flush()
+				return
+			}
+			bodySize := len(pending.message.Body)
+			if len(batch) > 0 && size+bodySize > b.config.maxBytes {
+				flush()
+			}
+			if len(batch) == 0 {
+				timer.Reset(b.config.linger)
+			}
+			batch = append(batch, pending)
+			size += bodySize
+			if len(batch) >= b.config.maxMessages || size >= b.config.maxBytes {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+func (b *batchingProducer) send(batch []pendingMessage) {
+	messages := make([]queueProducer.Message, len(batch))
+	for i, pending := range batch {
+		messages[i] = pending.message
+	}
+	errs := b.producer.SendBatch(messages)
+	for i, pending := range batch {
+		pending.outcome <- errs[i]
+	}
+}
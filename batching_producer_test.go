@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingBatchProducer struct {
+	sync.Mutex
+	batches [][]string
+}
+
+func (p *recordingBatchProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	return p.SendBatch([]queueProducer.Message{message})[0]
+}
+
+// SendBatch fails the messages whose body is "fail"
+func (p *recordingBatchProducer) SendBatch(messages []queueProducer.Message) []error {
+	p.Lock()
+	defer p.Unlock()
+	var bodies []string
+	errs := make([]error, len(messages))
+	for i, message := range messages {
+		bodies = append(bodies, message.Body)
+		if message.Body == "fail" {
+			errs[i] = errors.New("rejected " + message.Headers["X-Request-Id"])
+		}
+	}
+	p.batches = append(p.batches, bodies)
+	return errs
+}
+
+func (p *recordingBatchProducer) ConnectivityCheck() (string, error) {
+	return "OK", nil
+}
+
+func (p *recordingBatchProducer) recorded() [][]string {
+	p.Lock()
+	defer p.Unlock()
+	return p.batches
+}
+
+func TestBatchingProducerLimits(t *testing.T) {
+	var tests = []struct {
+		name     string
+		config   batchingConfig
+		bodies   []string
+		expected [][]string
+	}{
+		{"count", batchingConfig{maxMessages: 2, maxBytes: 1024, linger: time.Minute}, []string{"a", "b", "c", "d"}, [][]string{{"a", "b"}, {"c", "d"}}},
+		{"bytes", batchingConfig{maxMessages: 10, maxBytes: 4, linger: time.Minute}, []string{"aa", "bb", "ccc", "d"}, [][]string{{"aa", "bb"}, {"ccc", "d"}}},
+		{"linger", batchingConfig{maxMessages: 10, maxBytes: 1024, linger: 10 * time.Millisecond}, []string{"a", "b", "c"}, [][]string{{"a", "b", "c"}}},
+	}
+
+	for _, test := range tests {
+		recorder := &recordingBatchProducer{}
+		p, err := newBatchingProducer(recorder, test.config)
+		assert.NoError(t, err, test.name)
+		var outcomes []<-chan error
+		for _, body := range test.bodies {
+			outcomes = append(outcomes, p.sendAsync(queueProducer.Message{Body: body}))
+		}
+		for _, outcome := range outcomes {
+			assert.NoError(t, <-outcome, test.name)
+		}
+		assert.Equal(t, test.expected, recorder.recorded(), test.name)
+	}
+}
+
+func TestBatchingProducerOutcomes(t *testing.T) {
+	recorder := &recordingBatchProducer{}
+	p, err := newBatchingProducer(recorder, batchingConfig{maxMessages: 3, maxBytes: 1024, linger: time.Minute})
+	assert.NoError(t, err)
+
+	first := p.sendAsync(queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_first"}, Body: "ok"})
+	second := p.sendAsync(queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_second"}, Body: "fail"})
+	third := p.sendAsync(queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_third"}, Body: "ok"})
+	assert.NoError(t, <-first)
+	assert.EqualError(t, <-second, "rejected tid_second")
+	assert.NoError(t, <-third)
+	assert.Len(t, recorder.recorded(), 1)
+}
+
+type closingBatchProducer struct {
+	recordingBatchProducer
+	closed bool
+}
+
+func (p *closingBatchProducer) Close() error {
+	p.closed = true
+	return nil
+}
+
+func TestBatchingProducerClose(t *testing.T) {
+	inner := &closingBatchProducer{}
+	p, err := newBatchingProducer(inner, batchingConfig{maxMessages: 10, maxBytes: 1024, linger: time.Hour})
+	assert.NoError(t, err)
+
+	first := p.sendAsync(queueProducer.Message{Body: "a"})
+	second := p.sendAsync(queueProducer.Message{Body: "b"})
+	assert.NoError(t, p.Close())
+	assert.NoError(t, <-first, "The lingering batch is published on close")
+	assert.NoError(t, <-second)
+	assert.Equal(t, [][]string{{"a", "b"}}, inner.recorded())
+	assert.True(t, inner.closed, "The wrapped producer is closed")
+
+	assert.Error(t, p.SendMessage("", queueProducer.Message{Body: "c"}), "Messages sent after close fail")
+	assert.NoError(t, p.Close(), "Closing twice is harmless")
+}
+
+func TestNewBatchingProducerInvalid(t *testing.T) {
+	_, err := newBatchingProducer(&mockProducerInstance{}, batchingConfig{maxMessages: 10, maxBytes: 1024, linger: time.Second})
+	assert.Error(t, err, "The producer doesn't support batching")
+	_, err = newBatchingProducer(&recordingBatchProducer{}, batchingConfig{maxMessages: 10, maxBytes: 1024})
+	assert.Error(t, err, "The linger time is required")
+}
+
+func TestForwardBatchWithBatchingProducer(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	recorder := &recordingBatchProducer{}
+	p, err := newBatchingProducer(recorder, batchingConfig{maxMessages: 100, maxBytes: 1048576, linger: time.Minute})
+	assert.NoError(t, err)
+	bridge := BridgeApp{producerInstance: p}
+
+	var msgs []queueConsumer.Message
+	for i := 0; i < 5; i++ {
+		msgs = append(msgs, queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_" + strconv.Itoa(i)}, Body: strconv.Itoa(i)})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		bridge.forwardBatch(msgs)
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatal("The batch was handled before its messages were published")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	// a full batch is published straight away, releasing the forwarder
+	for i := 5; i < 100; i++ {
+		p.sendAsync(queueProducer.Message{Body: strconv.Itoa(i)})
+	}
+	<-done
+	assert.Len(t, recorder.recorded(), 1)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4"}, recorder.recorded()[0][:5])
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	fileBlobStoreType = "file"
+	s3BlobStoreType   = "s3"
+
+	fileBlobRefPrefix = "file:"
+	s3BlobRefPrefix   = "s3://"
+	// s3BlobKeyPrefix is prepended to the keys of the S3 blobs, the references outside it are refused
+	s3BlobKeyPrefix = "claim-check/"
+
+	blobStoreTimeout = 30 * time.Second
+)
+
+// blobStore keeps message bodies out of band. put returns a reference which get resolves back to the data.
+type blobStore interface {
+	put(key string, data []byte) (string, error)
+	get(ref string) ([]byte, error)
+}
+
+func newBlobStore(storeType string, dir string, bucket string, s3 s3Config) (blobStore, error) {
+	switch storeType {
+	case fileBlobStoreType:
+		if dir == "" {
+			return nil, errors.New("the blob store directory is required")
+		}
+		return newFileBlobStore(dir)
+	case s3BlobStoreType:
+		return newS3BlobStore(s3, bucket)
+	default:
+		return nil, errors.New("unknown blob store type " + storeType)
+	}
+}
+
+// fileBlobStore keeps the blobs in a local directory, which must be shared with the rehydrating side
+type fileBlobStore struct {
+	dir string
+}
+
+func newFileBlobStore(dir string) (*fileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileBlobStore{dir: dir}, nil
+}
+
+func (s *fileBlobStore) put(key string, data []byte) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return "", err
+	}
+	return fileBlobRefPrefix + key, nil
+}
+
+func (s *fileBlobStore) get(ref string) ([]byte, error) {
+	if !strings.HasPrefix(ref, fileBlobRefPrefix) {
+		return nil, errors.New("not a file blob reference: " + ref)
+	}
+	path, err := s.path(strings.TrimPrefix(ref, fileBlobRefPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+// path resolves the key inside the store directory, refusing keys which would escape it
+func (s *fileBlobStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + filepath.FromSlash(key))
+	if clean == string(filepath.Separator) || strings.Contains(key, "..") {
+		return "", errors.New("invalid blob key " + key)
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// s3BlobStore keeps the blobs in an S3-compatible bucket, under s3BlobKeyPrefix. References are read from
+// the messages, so only the ones to its own bucket and prefix are resolved: the bridge credentials may
+// reach other objects, which mustn't be injected as message bodies.
+type s3BlobStore struct {
+	client *minio.Client
+	bucket string
+}
+
+func newS3BlobStore(config s3Config, bucket string) (*s3BlobStore, error) {
+	if bucket == "" {
+		return nil, errors.New("the S3 bucket is required")
+	}
+	client, err := newS3Client(config)
+	if err != nil {
+		return nil, err
+	}
+	return &s3BlobStore{client: client, bucket: bucket}, nil
+}
+
+func (s *s3BlobStore) put(key string, data []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), blobStoreTimeout)
+	defer cancel()
+
+	key = s3BlobKeyPrefix + key
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: "application/octet-stream"})
+	if err != nil {
+		return "", err
+	}
+	return s3BlobRefPrefix + s.bucket + "/" + key, nil
+}
+
+func (s *s3BlobStore) get(ref string) ([]byte, error) {
+	location := strings.TrimPrefix(ref, s3BlobRefPrefix)
+	sep := strings.Index(location, "/")
+	if !strings.HasPrefix(ref, s3BlobRefPrefix) || sep <= 0 {
+		return nil, errors.New("not an s3 blob reference: " + ref)
+	}
+	bucket, key := location[:sep], location[sep+1:]
+	if bucket != s.bucket {
+		return nil, errors.New("the s3 blob reference " + ref + " isn't in bucket " + s.bucket)
+	}
+	if !strings.HasPrefix(key, s3BlobKeyPrefix) || key == s3BlobKeyPrefix || strings.Contains(key, "..") {
+		return nil, errors.New("invalid s3 blob key " + key)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), blobStoreTimeout)
+	defer cancel()
+	obj, err := s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return ioutil.ReadAll(obj)
+}
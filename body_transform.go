@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/Financial-Times/go-logger"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+)
+
+const (
+	transformSet       = "set"
+	transformRemove    = "remove"
+	transformRename    = "rename"
+	transformJSONPatch = "jsonPatch"
+	transformWrap      = "wrap"
+	transformUnwrap    = "unwrap"
+
+	onErrorFail = "fail"
+	onErrorSkip = "skip"
+)
+
+// transformStep is a single body transformation. Depending on the type it uses:
+//
+//	set: path, value - sets the (dotted) path to the JSON value
+//	remove: path - removes the field
+//	rename: path, to - moves the field to the new path
+//	jsonPatch: patch - applies an RFC 6902 JSON Patch
+//	wrap: field, envelope - nests the body under field, alongside the optional envelope fields
+//	unwrap: field - replaces the body with the value of field
+//
+// When a step fails the message is not forwarded, unless onError is "skip", in which case the step is ignored.
+type transformStep struct {
+	Type     string                     `json:"type"`
+	Path     string                     `json:"path,omitempty"`
+	To       string                     `json:"to,omitempty"`
+	Value    json.RawMessage            `json:"value,omitempty"`
+	Patch    json.RawMessage            `json:"patch,omitempty"`
+	Field    string                     `json:"field,omitempty"`
+	Envelope map[string]json.RawMessage `json:"envelope,omitempty"`
+	OnError  string                     `json:"onError,omitempty"`
+
+	patch jsonpatch.Patch
+}
+
+// bodyTransformPipeline applies an ordered list of transformations to message bodies.
+// A nil pipeline leaves the bodies untouched.
+type bodyTransformPipeline struct {
+	steps []*transformStep
+}
+
+// parseBodyTransforms parses and validates a JSON array of transformation steps
+func parseBodyTransforms(spec string) (*bodyTransformPipeline, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var steps []*transformStep
+	if err := json.Unmarshal([]byte(spec), &steps); err != nil {
+		return nil, err
+	}
+	for i, step := range steps {
+		if err := step.validate(); err != nil {
+			return nil, fmt.Errorf("invalid transformation step %d (%s): %v", i+1, step.Type, err)
+		}
+	}
+	return &bodyTransformPipeline{steps: steps}, nil
+}
+
+func (s *transformStep) validate() error {
+	switch s.OnError {
+	case "", onErrorFail, onErrorSkip:
+	default:
+		return errors.New("onError must be either fail or skip")
+	}
+
+	switch s.Type {
+	case transformSet:
+		if s.Path == "" || len(s.Value) == 0 {
+			return errors.New("path and value are required")
+		}
+		if _, err := decodeJSON(s.Value); err != nil {
+			return err
+		}
+	case transformRemove:
+		if s.Path == "" {
+			return errors.New("path is required")
+		}
+	case transformRename:
+		if s.Path == "" || s.To == "" {
+			return errors.New("path and to are required")
+		}
+	case transformJSONPatch:
+		patch, err := jsonpatch.DecodePatch(s.Patch)
+		if err != nil {
+			return err
+		}
+		s.patch = patch
+	case transformWrap, transformUnwrap:
+		if s.Field == "" {
+			return errors.New("field is required")
+		}
+	default:
+		return errors.New("unknown transformation type")
+	}
+	return nil
+}
+
+// apply runs the body through every step. Steps failing with onError "skip" are logged and ignored.
+func (p *bodyTransformPipeline) apply(tid string, body string) (string, error) {
+	if p == nil {
+		return body, nil
+	}
+
+	current := []byte(body)
+	for i, step := range p.steps {
+		transformed, err := step.apply(current)
+		if err != nil {
+			if step.OnError == onErrorSkip {
+				logger.NewEntry(tid).Warn(fmt.Sprintf("Skipping body transformation step %d (%s): %v", i+1, step.Type, err))
+				continue
+			}
+			return "", fmt.Errorf("body transformation step %d (%s) failed: %v", i+1, step.Type, err)
+		}
+		current = transformed
+	}
+	return string(current), nil
+}
+
+func (s *transformStep) apply(body []byte) ([]byte, error) {
+	if s.Type == transformJSONPatch {
+		return s.patch.Apply(body)
+	}
+
+	doc, err := decodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+
+	switch s.Type {
+	case transformSet:
+		// decoded for every message, so that messages never share the inserted value
+		value, err := decodeJSON(s.Value)
+		if err != nil {
+			return nil, err
+		}
+		if err := setJSONPath(doc, s.Path, value); err != nil {
+			return nil, err
+		}
+	case transformRemove:
+		if !removeJSONPath(doc, s.Path) {
+			return nil, errors.New("field " + s.Path + " not found")
+		}
+	case transformRename:
+		value, found := getJSONPath(doc, s.Path)
+		if !found {
+			return nil, errors.New("field " + s.Path + " not found")
+		}
+		removeJSONPath(doc, s.Path)
+		if err := setJSONPath(doc, s.To, value); err != nil {
+			return nil, err
+		}
+	case transformWrap:
+		envelope := make(map[string]interface{}, len(s.Envelope)+1)
+		for name, raw := range s.Envelope {
+			value, err := decodeJSON(raw)
+			if err != nil {
+				return nil, err
+			}
+			envelope[name] = value
+		}
+		envelope[s.Field] = doc
+		doc = envelope
+	case transformUnwrap:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("the body is not a JSON object")
+		}
+		value, found := obj[s.Field]
+		if !found {
+			return nil, errors.New("field " + s.Field + " not found")
+		}
+		doc = value
+	}
+	return encodeJSON(doc)
+}
+
+// previewBodyTransforms writes the outcome of every step, then the transformed body, for the sample read from the file
+func previewBodyTransforms(p *bodyTransformPipeline, sampleFile string, stdin io.Reader, out io.Writer) error {
+	var sample []byte
+	var err error
+	if sampleFile == "-" {
+		sample, err = ioutil.ReadAll(stdin)
+	} else {
+		sample, err = ioutil.ReadFile(sampleFile)
+	}
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		fmt.Fprintln(out, "# no body transformations configured")
+		fmt.Fprintln(out, string(sample))
+		return nil
+	}
+
+	current := sample
+	for i, step := range p.steps {
+		transformed, err := step.apply(current)
+		switch {
+		case err == nil:
+			fmt.Fprintf(out, "# step %d (%s): ok\n", i+1, step.Type)
+			current = transformed
+		case step.OnError == onErrorSkip:
+			fmt.Fprintf(out, "# step %d (%s): skipped, %v\n", i+1, step.Type, err)
+		default:
+			fmt.Fprintf(out, "# step %d (%s): failed, %v - the message would not be forwarded\n", i+1, step.Type, err)
+			return nil
+		}
+	}
+	fmt.Fprintln(out, string(current))
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/stretchr/testify/assert"
+)
+
+const transformTestBody = `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","type":"EOM::CompoundStory","value":"<p>test</p>","lastModified":12345678901234567890}`
+
+func TestBodyTransformPipeline(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	var tests = []struct {
+		name         string
+		spec         string
+		expectedBody string
+		expectedErr  bool
+	}{
+		{
+			"set nested field",
+			`[{"type":"set","path":"meta.bridged","value":true}]`,
+			`{"lastModified":12345678901234567890,"meta":{"bridged":true},"type":"EOM::CompoundStory","uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","value":"<p>test</p>"}`,
+			false,
+		},
+		{
+			"remove and rename",
+			`[{"type":"remove","path":"type"},{"type":"rename","path":"value","to":"payload.value"}]`,
+			`{"lastModified":12345678901234567890,"payload":{"value":"<p>test</p>"},"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			false,
+		},
+		{
+			"json patch",
+			`[{"type":"jsonPatch","patch":[{"op":"replace","path":"/type","value":"Article"},{"op":"remove","path":"/lastModified"}]}]`,
+			`{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","type":"Article","value":"<p>test</p>"}`,
+			false,
+		},
+		{
+			"wrap then unwrap",
+			`[{"type":"wrap","field":"payload","envelope":{"version":2}},{"type":"unwrap","field":"payload"},{"type":"remove","path":"value"}]`,
+			`{"lastModified":12345678901234567890,"type":"EOM::CompoundStory","uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			false,
+		},
+		{
+			"failing step is skipped",
+			`[{"type":"remove","path":"missing","onError":"skip"},{"type":"unwrap","field":"uuid"}]`,
+			`"7543220a-2389-11e5-bd83-71cb60e8f08c"`,
+			false,
+		},
+		{
+			"failing step fails the message",
+			`[{"type":"remove","path":"missing"},{"type":"unwrap","field":"uuid"}]`,
+			"",
+			true,
+		},
+	}
+
+	for _, test := range tests {
+		pipeline, err := parseBodyTransforms(test.spec)
+		assert.NoError(t, err, test.name)
+
+		body, err := pipeline.apply("tid_test", transformTestBody)
+		if test.expectedErr {
+			assert.Error(t, err, test.name)
+			continue
+		}
+		assert.NoError(t, err, test.name)
+		assert.JSONEq(t, test.expectedBody, body, test.name)
+		if test.name != "json patch" {
+			assert.Equal(t, test.expectedBody, body, test.name)
+		}
+	}
+}
+
+func TestParseBodyTransformsInvalid(t *testing.T) {
+	for _, spec := range []string{
+		`[{"type":"unknown"}]`,
+		`[{"type":"set","path":"a"}]`,
+		`[{"type":"rename","path":"a"}]`,
+		`[{"type":"jsonPatch","patch":{"op":"remove"}}]`,
+		`[{"type":"wrap"}]`,
+		`[{"type":"remove","path":"a","onError":"ignore"}]`,
+		`{"type":"remove","path":"a"}`,
+	} {
+		_, err := parseBodyTransforms(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestNilBodyTransformPipeline(t *testing.T) {
+	var pipeline *bodyTransformPipeline
+	body, err := pipeline.apply("tid_test", "not json")
+	assert.NoError(t, err)
+	assert.Equal(t, "not json", body)
+}
+
+func TestPreviewBodyTransforms(t *testing.T) {
+	pipeline, err := parseBodyTransforms(`[{"type":"remove","path":"missing","onError":"skip"},{"type":"unwrap","field":"uuid"}]`)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, previewBodyTransforms(pipeline, "-", strings.NewReader(transformTestBody), &out))
+	assert.Equal(t, "# step 1 (remove): skipped, field missing not found\n# step 2 (unwrap): ok\n\"7543220a-2389-11e5-bd83-71cb60e8f08c\"\n", out.String())
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"path"
+	"strconv"
+	"time"
+)
+
+const (
+	claimCheckStoreMode     = "store"
+	claimCheckRehydrateMode = "rehydrate"
+
+	claimCheckRefHeader         = "X-Claim-Check"
+	claimCheckSHA256Header      = "X-Claim-Check-Sha256"
+	claimCheckSizeHeader        = "X-Claim-Check-Size"
+	claimCheckContentTypeHeader = "X-Claim-Check-Content-Type"
+	claimCheckContentType       = "application/vnd.ft-upp-claim-check+json"
+)
+
+var claimCheckHeaders = []string{claimCheckRefHeader, claimCheckSHA256Header, claimCheckSizeHeader, claimCheckContentTypeHeader}
+
+// claimCheck implements the claim-check pattern: in store mode, bodies over the threshold are put in a blob store
+// and replaced by a small reference message; in rehydrate mode, reference messages are replaced by the stored body.
+// A nil claimCheck leaves every message untouched.
+type claimCheck struct {
+	mode      string
+	threshold int
+	store     blobStore
+	topic     string
+}
+
+type claimCheckReference struct {
+	ClaimCheck string `json:"claimCheck"`
+	SHA256     string `json:"sha256"`
+	Size       int    `json:"size"`
+}
+
+func newClaimCheck(mode string, threshold int, store blobStore, topic string) (*claimCheck, error) {
+	switch mode {
+	case "":
+		return nil, nil
+	case claimCheckStoreMode, claimCheckRehydrateMode:
+		if store == nil {
+			return nil, errors.New("a blob store is required for the claim check")
+		}
+		return &claimCheck{mode: mode, threshold: threshold, store: store, topic: topic}, nil
+	default:
+		return nil, errors.New("unknown claim check mode " + mode)
+	}
+}
+
+// checkIn stores the body if it is over the threshold and returns the reference message body,
+// the headers are updated to describe the reference
+func (c *claimCheck) checkIn(headers map[string]string, body string) (string, error) {
+	if c == nil || c.mode != claimCheckStoreMode || len(body) <= c.threshold {
+		return body, nil
+	}
+
+	sum := sha256.Sum256([]byte(body))
+	digest := hex.EncodeToString(sum[:])
+	key := path.Join(c.topic, time.Now().UTC().Format("2006/01/02"), digest)
+	ref, err := c.store.put(key, []byte(body))
+	if err != nil {
+		return "", errors.New("couldn't check the message body in: " + err.Error())
+	}
+
+	reference, err := json.Marshal(claimCheckReference{ClaimCheck: ref, SHA256: digest, Size: len(body)})
+	if err != nil {
+		return "", err
+	}
+	h := messageHeaders(headers)
+	h.Set(claimCheckRefHeader, ref)
+	h.Set(claimCheckSHA256Header, digest)
+	h.Set(claimCheckSizeHeader, strconv.Itoa(len(body)))
+	if contentType, found := h.Lookup("Content-Type"); found {
+		h.Set(claimCheckContentTypeHeader, contentType)
+	}
+	h.Set("Content-Type", claimCheckContentType)
+	return string(reference), nil
+}
+
+// rehydrate returns the stored body if the message is a claim-check reference, restoring the original headers
+func (c *claimCheck) rehydrate(headers map[string]string, body string) (string, error) {
+	if c == nil || c.mode != claimCheckRehydrateMode {
+		return body, nil
+	}
+	h := messageHeaders(headers)
+	ref, found := h.Lookup(claimCheckRefHeader)
+	if !found {
+		return body, nil
+	}
+
+	data, err := c.store.get(ref)
+	if err != nil {
+		return "", errors.New("couldn't rehydrate claim check " + ref + ": " + err.Error())
+	}
+	sum := sha256.Sum256(data)
+	if expected := h.Get(claimCheckSHA256Header); expected != "" && expected != hex.EncodeToString(sum[:]) {
+		return "", errors.New("claim check " + ref + " doesn't match its checksum")
+	}
+
+	if contentType, found := h.Lookup(claimCheckContentTypeHeader); found {
+		h.Set("Content-Type", contentType)
+	} else {
+		h.Del("Content-Type")
+	}
+	for _, name := range claimCheckHeaders {
+		h.Del(name)
+	}
+	return string(data), nil
+}
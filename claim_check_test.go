@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestBlobStore(t *testing.T) (*fileBlobStore, func()) {
+	dir, err := ioutil.TempDir("", "claim-check")
+	assert.NoError(t, err)
+	store, err := newFileBlobStore(dir)
+	assert.NoError(t, err)
+	return store, func() { os.RemoveAll(dir) }
+}
+
+func TestClaimCheckRoundTrip(t *testing.T) {
+	store, cleanup := newTestBlobStore(t)
+	defer cleanup()
+
+	checker, err := newClaimCheck(claimCheckStoreMode, 100, store, "NativeCmsPublicationEvents")
+	assert.NoError(t, err)
+	rehydrator, err := newClaimCheck(claimCheckRehydrateMode, 100, store, "")
+	assert.NoError(t, err)
+
+	largeBody := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","value":"` + strings.Repeat("test ", 50) + `"}`
+	headers := map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"}
+
+	reference, err := checker.checkIn(headers, largeBody)
+	assert.NoError(t, err)
+	assert.Equal(t, claimCheckContentType, headers["Content-Type"])
+	assert.Equal(t, "application/json", headers[claimCheckContentTypeHeader])
+	assert.True(t, strings.HasPrefix(headers[claimCheckRefHeader], "file:NativeCmsPublicationEvents/"))
+
+	ref := claimCheckReference{}
+	assert.NoError(t, json.Unmarshal([]byte(reference), &ref))
+	assert.Equal(t, headers[claimCheckRefHeader], ref.ClaimCheck)
+	assert.Equal(t, headers[claimCheckSHA256Header], ref.SHA256)
+	assert.Equal(t, len(largeBody), ref.Size)
+
+	body, err := rehydrator.rehydrate(headers, reference)
+	assert.NoError(t, err)
+	assert.Equal(t, largeBody, body)
+	assert.Equal(t, map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"}, headers)
+}
+
+func TestClaimCheckPassThrough(t *testing.T) {
+	store, cleanup := newTestBlobStore(t)
+	defer cleanup()
+
+	smallBody := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`
+	var tests = []struct {
+		name    string
+		check   *claimCheck
+		headers map[string]string
+	}{
+		{"disabled", nil, map[string]string{"X-Request-Id": "tid_test"}},
+		{"below threshold", &claimCheck{mode: claimCheckStoreMode, threshold: 100, store: store}, map[string]string{"X-Request-Id": "tid_test"}},
+		{"not a reference", &claimCheck{mode: claimCheckRehydrateMode, store: store}, map[string]string{"X-Request-Id": "tid_test"}},
+	}
+
+	for _, test := range tests {
+		body, err := test.check.checkIn(test.headers, smallBody)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, smallBody, body, test.name)
+		body, err = test.check.rehydrate(test.headers, smallBody)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, smallBody, body, test.name)
+		assert.Equal(t, map[string]string{"X-Request-Id": "tid_test"}, test.headers, test.name)
+	}
+}
+
+func TestClaimCheckRehydrateFailures(t *testing.T) {
+	store, cleanup := newTestBlobStore(t)
+	defer cleanup()
+	ref, err := store.put("topic/blob", []byte("stored"))
+	assert.NoError(t, err)
+
+	rehydrator := &claimCheck{mode: claimCheckRehydrateMode, store: store}
+	var tests = []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"missing blob", map[string]string{claimCheckRefHeader: "file:topic/missing"}},
+		{"checksum mismatch", map[string]string{claimCheckRefHeader: ref, claimCheckSHA256Header: "0000"}},
+		{"escaping key", map[string]string{claimCheckRefHeader: "file:../etc/passwd"}},
+	}
+
+	for _, test := range tests {
+		_, err := rehydrator.rehydrate(test.headers, "{}")
+		assert.Error(t, err, test.name)
+	}
+}
+
+func TestNewClaimCheckInvalid(t *testing.T) {
+	_, err := newClaimCheck("archive", 0, &fileBlobStore{}, "")
+	assert.Error(t, err)
+	_, err = newClaimCheck(claimCheckStoreMode, 0, nil, "")
+	assert.Error(t, err)
+
+	check, err := newClaimCheck("", 0, nil, "")
+	assert.NoError(t, err)
+	assert.Nil(t, check)
+}
+
+func TestS3BlobStoreReferences(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(s3)
+	defer server.Close()
+	store, err := newS3BlobStore(s3Config{endpoint: server.URL, region: "eu-west-1", accessKeyID: "key", secretAccessKey: "secret"}, "claims")
+	assert.NoError(t, err)
+
+	ref, err := store.put("topic/2026/10/19/digest", []byte("stored"))
+	assert.NoError(t, err)
+	assert.Equal(t, "s3://claims/claim-check/topic/2026/10/19/digest", ref)
+	data, err := store.get(ref)
+	assert.NoError(t, err)
+	assert.Equal(t, "stored", string(data))
+
+	s3.objects["/secrets/claim-check/topic/digest"] = []byte("secret")
+	s3.objects["/claims/config.json"] = []byte("secret")
+	var tests = []struct {
+		name string
+		ref  string
+	}{
+		{"other bucket", "s3://secrets/claim-check/topic/digest"},
+		{"outside the prefix", "s3://claims/config.json"},
+		{"escaping the prefix", "s3://claims/claim-check/../config.json"},
+		{"prefix only", "s3://claims/claim-check/"},
+		{"no key", "s3://claims"},
+		{"file reference", "file:topic/digest"},
+	}
+	for _, test := range tests {
+		_, err := store.get(test.ref)
+		assert.Error(t, err, test.name)
+	}
+}
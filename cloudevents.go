@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	ftOutputFormat                    = "ft"
+	cloudEventsStructuredOutputFormat = "cloudevents-structured"
+	cloudEventsBinaryOutputFormat     = "cloudevents-binary"
+
+	cloudEventsSpecVersion           = "1.0"
+	cloudEventsStructuredContentType = "application/cloudevents+json"
+	cloudEventsBinaryHeaderPrefix    = "ce-"
+	cloudEventsDefaultContentType    = "application/json"
+)
+
+// cloudEventsHeaderAttributes maps the FT message headers onto CloudEvents attributes.
+// X-Request-Id has no CloudEvents counterpart and is carried by the requestid extension.
+var cloudEventsHeaderAttributes = []struct {
+	header    string
+	attribute string
+}{
+	{"Message-Id", "id"},
+	{"Message-Type", "type"},
+	{"Origin-System-Id", "source"},
+	{"Message-Timestamp", "time"},
+	{"X-Request-Id", "requestid"},
+}
+
+// cloudEventsBinaryHeaders are the headers set in binary mode, which the plainHTTP producer must keep
+var cloudEventsBinaryHeaders = []string{
+	cloudEventsBinaryHeaderPrefix + "specversion",
+	cloudEventsBinaryHeaderPrefix + "id",
+	cloudEventsBinaryHeaderPrefix + "type",
+	cloudEventsBinaryHeaderPrefix + "source",
+	cloudEventsBinaryHeaderPrefix + "time",
+	cloudEventsBinaryHeaderPrefix + "requestid",
+}
+
+// cloudEventsEncoder converts FT messages to CloudEvents, in structured mode (the event is the JSON body)
+// or in binary mode (the attributes are ce- headers and the body is the event data).
+// A nil cloudEventsEncoder leaves messages in the FT format.
+type cloudEventsEncoder struct {
+	structured    bool
+	defaultSource string
+}
+
+func newCloudEventsEncoder(outputFormat string, defaultSource string) (*cloudEventsEncoder, error) {
+	switch outputFormat {
+	case "", ftOutputFormat:
+		return nil, nil
+	case cloudEventsStructuredOutputFormat:
+		return &cloudEventsEncoder{structured: true, defaultSource: defaultSource}, nil
+	case cloudEventsBinaryOutputFormat:
+		return &cloudEventsEncoder{defaultSource: defaultSource}, nil
+	default:
+		return nil, errors.New("unknown output format " + outputFormat)
+	}
+}
+
+// encode returns the body of the event, updating the headers. The FT headers mapped onto attributes are removed,
+// except X-Request-Id which the producers rely on for the transaction id.
+func (e *cloudEventsEncoder) encode(headers map[string]string, body string) (string, error) {
+	if e == nil {
+		return body, nil
+	}
+
+	h := messageHeaders(headers)
+	attributes := map[string]string{"specversion": cloudEventsSpecVersion}
+	for _, mapping := range cloudEventsHeaderAttributes {
+		if value := h.Get(mapping.header); value != "" {
+			attributes[mapping.attribute] = value
+		}
+		if mapping.header != "X-Request-Id" {
+			h.Del(mapping.header)
+		}
+	}
+	if attributes["id"] == "" {
+		attributes["id"] = attributes["requestid"]
+	}
+	if attributes["id"] == "" || attributes["type"] == "" {
+		return "", errors.New("couldn't convert to a CloudEvent without Message-Id and Message-Type headers")
+	}
+	if attributes["source"] == "" {
+		attributes["source"] = e.defaultSource
+	}
+	if timestamp, found := attributes["time"]; found {
+		parsed, err := time.Parse(time.RFC3339Nano, timestamp)
+		if err != nil {
+			return "", errors.New("couldn't convert Message-Timestamp to a CloudEvents time: " + err.Error())
+		}
+		attributes["time"] = parsed.UTC().Format(time.RFC3339Nano)
+	}
+	contentType := h.Get("Content-Type")
+	if contentType == "" {
+		contentType = cloudEventsDefaultContentType
+	}
+
+	if !e.structured {
+		for attribute, value := range attributes {
+			h.Set(cloudEventsBinaryHeaderPrefix+attribute, value)
+		}
+		h.Set("Content-Type", contentType)
+		return body, nil
+	}
+
+	event := make(map[string]interface{}, len(attributes)+2)
+	for attribute, value := range attributes {
+		event[attribute] = value
+	}
+	event["datacontenttype"] = contentType
+	if isJSONContentType(contentType) && json.Valid([]byte(body)) {
+		event["data"] = json.RawMessage(body)
+	} else {
+		event["data"] = body
+	}
+	encoded, err := encodeJSON(event)
+	if err != nil {
+		return "", err
+	}
+	h.Set("Content-Type", cloudEventsStructuredContentType)
+	return string(encoded), nil
+}
+
+func isJSONContentType(contentType string) bool {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ftTestHeaders() map[string]string {
+	return map[string]string{
+		"Message-Id":        "c4b96810-03e8-4057-84c5-dcc3a8d3b3a6",
+		"Message-Type":      "cms-content-published",
+		"Origin-System-Id":  "http://cmdb.ft.com/systems/methode-web-pub",
+		"Message-Timestamp": "2015-07-06T07:03:09.362Z",
+		"X-Request-Id":      "tid_test",
+		"Content-Type":      "application/json",
+		"X-Schema-Version":  "2",
+	}
+}
+
+func TestCloudEventsStructured(t *testing.T) {
+	encoder, err := newCloudEventsEncoder(cloudEventsStructuredOutputFormat, "/kafka-bridge")
+	assert.NoError(t, err)
+
+	var tests = []struct {
+		name         string
+		contentType  string
+		body         string
+		expectedBody string
+	}{
+		{
+			"JSON data",
+			"application/json",
+			`{"uuid": "7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			`{"data":{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"},"datacontenttype":"application/json","id":"c4b96810-03e8-4057-84c5-dcc3a8d3b3a6","requestid":"tid_test","source":"http://cmdb.ft.com/systems/methode-web-pub","specversion":"1.0","time":"2015-07-06T07:03:09.362Z","type":"cms-content-published"}`,
+		},
+		{
+			"text data",
+			"application/xml",
+			`<content/>`,
+			`{"data":"<content/>","datacontenttype":"application/xml","id":"c4b96810-03e8-4057-84c5-dcc3a8d3b3a6","requestid":"tid_test","source":"http://cmdb.ft.com/systems/methode-web-pub","specversion":"1.0","time":"2015-07-06T07:03:09.362Z","type":"cms-content-published"}`,
+		},
+	}
+
+	for _, test := range tests {
+		headers := ftTestHeaders()
+		headers["Content-Type"] = test.contentType
+		body, err := encoder.encode(headers, test.body)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expectedBody, body, test.name)
+		assert.Equal(t, map[string]string{"X-Request-Id": "tid_test", "Content-Type": cloudEventsStructuredContentType, "X-Schema-Version": "2"}, headers, test.name)
+	}
+}
+
+func TestCloudEventsBinary(t *testing.T) {
+	encoder, err := newCloudEventsEncoder(cloudEventsBinaryOutputFormat, "/kafka-bridge")
+	assert.NoError(t, err)
+
+	headers := ftTestHeaders()
+	delete(headers, "Origin-System-Id")
+	body, err := encoder.encode(headers, `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`, body)
+	assert.Equal(t, map[string]string{
+		"ce-specversion":   "1.0",
+		"ce-id":            "c4b96810-03e8-4057-84c5-dcc3a8d3b3a6",
+		"ce-type":          "cms-content-published",
+		"ce-source":        "/kafka-bridge",
+		"ce-time":          "2015-07-06T07:03:09.362Z",
+		"ce-requestid":     "tid_test",
+		"X-Request-Id":     "tid_test",
+		"Content-Type":     "application/json",
+		"X-Schema-Version": "2",
+	}, headers)
+}
+
+func TestCloudEventsInvalid(t *testing.T) {
+	encoder, err := newCloudEventsEncoder(cloudEventsBinaryOutputFormat, "/kafka-bridge")
+	assert.NoError(t, err)
+
+	headers := ftTestHeaders()
+	delete(headers, "Message-Type")
+	_, err = encoder.encode(headers, "{}")
+	assert.Error(t, err)
+
+	headers = ftTestHeaders()
+	headers["Message-Timestamp"] = "yesterday"
+	_, err = encoder.encode(headers, "{}")
+	assert.Error(t, err)
+
+	_, err = newCloudEventsEncoder("avro", "")
+	assert.Error(t, err)
+	disabled, err := newCloudEventsEncoder(ftOutputFormat, "")
+	assert.NoError(t, err)
+	assert.Nil(t, disabled)
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+const (
+	confluentRESTv2 = "v2"
+	confluentRESTv3 = "v3"
+
+	embeddedFormatBinary = "binary"
+	embeddedFormatJSON   = "json"
+)
+
+// batchProducer is implemented by the producers able to send several messages in a single request.
+// SendBatch returns one error per message, nil for the messages which were sent.
+type batchProducer interface {
+	SendBatch(messages []queueProducer.Message) []error
+}
+
+// confluentRESTConfig holds the settings specific to the Confluent REST Proxy producer
+type confluentRESTConfig struct {
+	apiVersion     string
+	embeddedFormat string
+	clusterID      string
+}
+
+// confluentRESTMessageProducer produces through the records API of a Confluent REST Proxy, e.g. kafka-rest-proxy-msk.
+// With the binary embedded format the record value is the FT message, as written by the kafka proxy;
+// with the JSON format it is the message body, which must be JSON. The v2 API has no record headers,
+// v3 also sets the message headers as record headers. Records are keyed by content UUID like the kafka producer.
+type confluentRESTMessageProducer struct {
+	config queueProducer.MessageProducerConfig
+	rest   confluentRESTConfig
+	client plainHttpClient
+}
+
+func newConfluentRESTMessageProducer(config queueProducer.MessageProducerConfig, rest confluentRESTConfig) (*confluentRESTMessageProducer, error) {
+	switch rest.apiVersion {
+	case "":
+		rest.apiVersion = confluentRESTv2
+	case confluentRESTv2:
+	case confluentRESTv3:
+		if rest.clusterID == "" {
+			return nil, errors.New("the cluster id is required by the v3 API")
+		}
+	default:
+		return nil, errors.New("unsupported REST proxy API version " + rest.apiVersion)
+	}
+	switch rest.embeddedFormat {
+	case "":
+		rest.embeddedFormat = embeddedFormatBinary
+	case embeddedFormatBinary, embeddedFormatJSON:
+	default:
+		return nil, errors.New("unsupported embedded format " + rest.embeddedFormat)
+	}
+
+	return &confluentRESTMessageProducer{
+		config: config,
+		rest:   rest,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 100,
+				Dial: (&net.Dialer{
+					KeepAlive: 30 * time.Second,
+				}).Dial,
+			}},
+	}, nil
+}
+
+func (p *confluentRESTMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	record, err := p.record(uuid, message)
+	if err != nil {
+		return err
+	}
+	if err := p.send([]interface{}{record})[0]; err != nil {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+	}
+	return nil
+}
+
+func (p *confluentRESTMessageProducer) SendBatch(messages []queueProducer.Message) []error {
+	errs := make([]error, len(messages))
+	var records []interface{}
+	var sent []int
+	for i, message := range messages {
+		record, err := p.record("", message)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		records = append(records, record)
+		sent = append(sent, i)
+	}
+	if len(records) == 0 {
+		return errs
+	}
+	for i, err := range p.send(records) {
+		errs[sent[i]] = err
+	}
+	return errs
+}
+
+func (p *confluentRESTMessageProducer) ConnectivityCheck() (string, error) {
+	req, err := http.NewRequest("GET", p.topicURL(), nil)
+	if err != nil {
+		return "Forwarding messages is broken. Error creating the REST proxy healthcheck request", err
+	}
+	p.authorize(req)
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "Forwarding messages is broken. Error executing GET request. ", err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return "Forwarding messages is broken. The REST proxy doesn't know the topic " + p.config.Topic, fmt.Errorf("Status: %d", resp.StatusCode)
+	}
+	return "Connectivity to the REST proxy is OK", nil
+}
+
+type restV2Record struct {
+	Key   interface{} `json:"key,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+type restV3Data struct {
+	Type string      `json:"type"`
+	Data interface{} `json:"data"`
+}
+
+type restV3Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type restV3Record struct {
+	Key     *restV3Data    `json:"key,omitempty"`
+	Value   restV3Data     `json:"value"`
+	Headers []restV3Header `json:"headers,omitempty"`
+}
+
+type restV2Response struct {
+	Offsets []struct {
+		Partition *int32  `json:"partition"`
+		Offset    *int64  `json:"offset"`
+		ErrorCode *int    `json:"error_code"`
+		Error     *string `json:"error"`
+	} `json:"offsets"`
+}
+
+type restV3Response struct {
+	ErrorCode int    `json:"error_code"`
+	Message   string `json:"message"`
+}
+
+// record builds the record of the message in the format of the configured API version
+func (p *confluentRESTMessageProducer) record(uuid string, message queueProducer.Message) (interface{}, error) {
+	key := recordKey(uuid, message)
+	var value interface{}
+	if p.rest.embeddedFormat == embeddedFormatJSON {
+		if !json.Valid([]byte(message.Body)) {
+			return nil, fmt.Errorf("Forwarding message with tid: %s is not successful: the body is not JSON", messageHeaders(message.Headers).Get("X-Request-Id"))
+		}
+		value = json.RawMessage(message.Body)
+	} else {
+		value = base64.StdEncoding.EncodeToString([]byte(buildFTMessage(message)))
+	}
+
+	if p.rest.apiVersion == confluentRESTv2 {
+		record := restV2Record{Value: value}
+		if key != "" {
+			record.Key = p.encodeKey(key)
+		}
+		return record, nil
+	}
+
+	dataType := "BINARY"
+	if p.rest.embeddedFormat == embeddedFormatJSON {
+		dataType = "JSON"
+	}
+	record := restV3Record{Value: restV3Data{Type: dataType, Data: value}}
+	if key != "" {
+		record.Key = &restV3Data{Type: dataType, Data: p.encodeKey(key)}
+	}
+	for _, name := range sortedHeaderNames(message.Headers) {
+		record.Headers = append(record.Headers, restV3Header{Name: name, Value: base64.StdEncoding.EncodeToString([]byte(message.Headers[name]))})
+	}
+	return record, nil
+}
+
+func (p *confluentRESTMessageProducer) encodeKey(key string) interface{} {
+	if p.rest.embeddedFormat == embeddedFormatJSON {
+		return key
+	}
+	return base64.StdEncoding.EncodeToString([]byte(key))
+}
+
+// send posts the records in a single request and returns one error per record
+func (p *confluentRESTMessageProducer) send(records []interface{}) []error {
+	errs := make([]error, len(records))
+	fail := func(err error) []error {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	var body bytes.Buffer
+	contentType := "application/json"
+	if p.rest.apiVersion == confluentRESTv2 {
+		contentType = "application/vnd.kafka." + p.rest.embeddedFormat + ".v2+json"
+		if err := json.NewEncoder(&body).Encode(map[string]interface{}{"records": records}); err != nil {
+			return fail(err)
+		}
+	} else {
+		// the v3 API streams the records: each one is a JSON document and gets its own JSON response
+		encoder := json.NewEncoder(&body)
+		for _, record := range records {
+			if err := encoder.Encode(record); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	req, err := http.NewRequest("POST", p.recordsURL(), &body)
+	if err != nil {
+		return fail(fmt.Errorf("Error creating new request: %v", err))
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	p.authorize(req)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return fail(fmt.Errorf("Error executing POST request to the REST proxy: %v", err))
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fail(fmt.Errorf("Producing to the REST proxy is not successful. Status: %d %s", resp.StatusCode, strings.TrimSpace(string(respBody))))
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	if p.rest.apiVersion == confluentRESTv2 {
+		response := restV2Response{}
+		if err := decoder.Decode(&response); err != nil {
+			return fail(fmt.Errorf("Error reading the REST proxy response: %v", err))
+		}
+		for i := range errs {
+			if i >= len(response.Offsets) {
+				errs[i] = errors.New("no offset returned by the REST proxy for the record")
+			} else if offset := response.Offsets[i]; offset.ErrorCode != nil || offset.Error != nil {
+				errs[i] = restRecordError(offset.ErrorCode, offset.Error)
+			}
+		}
+		return errs
+	}
+
+	for i := range errs {
+		response := restV3Response{}
+		if err := decoder.Decode(&response); err != nil {
+			errs[i] = fmt.Errorf("Error reading the REST proxy response: %v", err)
+		} else if response.ErrorCode != http.StatusOK {
+			errs[i] = restRecordError(&response.ErrorCode, &response.Message)
+		}
+	}
+	return errs
+}
+
+func restRecordError(code *int, message *string) error {
+	var parts []string
+	if code != nil {
+		parts = append(parts, fmt.Sprintf("error code %d", *code))
+	}
+	if message != nil && *message != "" {
+		parts = append(parts, *message)
+	}
+	return errors.New("the REST proxy rejected the record: " + strings.Join(parts, ", "))
+}
+
+func (p *confluentRESTMessageProducer) topicURL() string {
+	addr := strings.TrimRight(p.config.Addr, "/")
+	if p.rest.apiVersion == confluentRESTv3 {
+		return addr + "/v3/clusters/" + url.PathEscape(p.rest.clusterID) + "/topics/" + url.PathEscape(p.config.Topic)
+	}
+	return addr + "/topics/" + url.PathEscape(p.config.Topic)
+}
+
+func (p *confluentRESTMessageProducer) recordsURL() string {
+	if p.rest.apiVersion == confluentRESTv3 {
+		return p.topicURL() + "/records"
+	}
+	return p.topicURL()
+}
+
+func (p *confluentRESTMessageProducer) authorize(req *http.Request) {
+	if len(p.config.Authorization) > 0 {
+		req.Header.Set("Authorization", p.config.Authorization)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+var restTestMessages = []queueProducer.Message{
+	{Headers: map[string]string{"X-Request-Id": "tid_first"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+	{Headers: map[string]string{"X-Request-Id": "tid_second"}, Body: `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`},
+}
+
+func TestConfluentRESTv2Batch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/topics/NativeCmsPublicationEvents", r.URL.Path)
+		assert.Equal(t, "application/vnd.kafka.binary.v2+json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Basic secret", r.Header.Get("Authorization"))
+
+		request := struct {
+			Records []restV2Record `json:"records"`
+		}{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&request))
+		assert.Len(t, request.Records, 2)
+		key, _ := base64.StdEncoding.DecodeString(request.Records[0].Key.(string))
+		assert.Equal(t, "7543220a-2389-11e5-bd83-71cb60e8f08c", string(key))
+		value, _ := base64.StdEncoding.DecodeString(request.Records[0].Value.(string))
+		assert.Equal(t, buildFTMessage(restTestMessages[0]), string(value))
+
+		w.Write([]byte(`{"key_schema_id":null,"value_schema_id":null,"offsets":[{"partition":0,"offset":12,"error_code":null,"error":null},{"partition":null,"offset":null,"error_code":50002,"error":"Kafka error: not leader"}]}`))
+	}))
+	defer server.Close()
+
+	p, err := newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{Addr: server.URL, Topic: "NativeCmsPublicationEvents", Authorization: "Basic secret"}, confluentRESTConfig{})
+	assert.NoError(t, err)
+	errs := p.SendBatch(restTestMessages)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "the REST proxy rejected the record: error code 50002, Kafka error: not leader")
+}
+
+func TestConfluentRESTv3JSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "GET" {
+			assert.Equal(t, "/v3/clusters/lkc-1/topics/NativeCmsPublicationEvents", r.URL.Path)
+			return
+		}
+		assert.Equal(t, "/v3/clusters/lkc-1/topics/NativeCmsPublicationEvents/records", r.URL.Path)
+		body, _ := ioutil.ReadAll(r.Body)
+		records := strings.Split(strings.TrimSpace(string(body)), "\n")
+		assert.Len(t, records, 2)
+		assert.Equal(t, `{"key":{"type":"JSON","data":"7543220a-2389-11e5-bd83-71cb60e8f08c"},"value":{"type":"JSON","data":{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}},"headers":[{"name":"X-Request-Id","value":"dGlkX2ZpcnN0"}]}`, records[0])
+
+		w.Write([]byte(`{"error_code":200,"cluster_id":"lkc-1","topic_name":"NativeCmsPublicationEvents","partition_id":1,"offset":3}
+{"error_code":400,"message":"Bad record"}
+`))
+	}))
+	defer server.Close()
+
+	p, err := newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{Addr: server.URL, Topic: "NativeCmsPublicationEvents"}, confluentRESTConfig{apiVersion: confluentRESTv3, embeddedFormat: embeddedFormatJSON, clusterID: "lkc-1"})
+	assert.NoError(t, err)
+	errs := p.SendBatch(restTestMessages)
+	assert.NoError(t, errs[0])
+	assert.EqualError(t, errs[1], "the REST proxy rejected the record: error code 400, Bad record")
+
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	err = p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_xml"}, Body: "<content/>"})
+	assert.Error(t, err, "The JSON embedded format requires JSON bodies")
+}
+
+func TestConfluentRESTRequestFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error_code":40401,"message":"Topic not found."}`))
+	}))
+	defer server.Close()
+
+	p, err := newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{Addr: server.URL, Topic: "Missing"}, confluentRESTConfig{})
+	assert.NoError(t, err)
+	for _, err := range p.SendBatch(restTestMessages) {
+		assert.Error(t, err)
+	}
+	err = p.SendMessage("", restTestMessages[0])
+	assert.Contains(t, err.Error(), "tid_first")
+	_, err = p.ConnectivityCheck()
+	assert.Error(t, err)
+}
+
+func TestNewConfluentRESTMessageProducerInvalid(t *testing.T) {
+	_, err := newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{}, confluentRESTConfig{apiVersion: confluentRESTv3})
+	assert.Error(t, err, "The cluster id is required by v3")
+	_, err = newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{}, confluentRESTConfig{apiVersion: "v1"})
+	assert.Error(t, err)
+	_, err = newConfluentRESTMessageProducer(queueProducer.MessageProducerConfig{}, confluentRESTConfig{embeddedFormat: "avro"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+)
+
+// deadLetterStore keeps the messages which are not forwarded, along with the reason why
+type deadLetterStore interface {
+	store(msg queueConsumer.Message, reason string) error
+}
+
+type deadLetterRecord struct {
+	DeadLetteredAt string            `json:"deadLetteredAt"`
+	Reason         string            `json:"reason"`
+	Headers        map[string]string `json:"headers"`
+	Body           string            `json:"body"`
+}
+
+// fileDeadLetterStore appends the dead letters as NDJSON to one file per day
+type fileDeadLetterStore struct {
+	sync.Mutex
+	dir string
+}
+
+func newFileDeadLetterStore(dir string) (*fileDeadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileDeadLetterStore{dir: dir}, nil
+}
+
+func (s *fileDeadLetterStore) store(msg queueConsumer.Message, reason string) error {
+	now := time.Now().UTC()
+	line, err := json.Marshal(deadLetterRecord{
+		DeadLetteredAt: now.Format(time.RFC3339Nano),
+		Reason:         reason,
+		Headers:        msg.Headers,
+		Body:           msg.Body,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	f, err := os.OpenFile(filepath.Join(s.dir, fmt.Sprintf("dead-letters-%s.ndjson", now.Format("2006-01-02"))), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// deadLetter records a message which won't be forwarded. Without a dead-letter store the message is only logged.
+func (bridge BridgeApp) deadLetter(tid string, msg queueConsumer.Message, reason string) {
+	entry := logger.NewMonitoringEntry("Forwarding", tid, messageHeaders(msg.Headers).Get("Content-Type"))
+	if bridge.deadLetters == nil {
+		entry.Error("Message was not forwarded and no dead-letter store is configured, " + reason)
+		return
+	}
+	if err := bridge.deadLetters.store(msg, reason); err != nil {
+		entry.Error("Message was not forwarded and couldn't be dead-lettered (" + err.Error() + "), " + reason)
+		return
+	}
+	entry.Error("Message was not forwarded and has been dead-lettered, " + reason)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingDeadLetterStore keeps the reasons the messages were dead-lettered for
+type recordingDeadLetterStore struct {
+	reasons []string
+}
+
+func (s *recordingDeadLetterStore) store(msg queueConsumer.Message, reason string) error {
+	s.reasons = append(s.reasons, reason)
+	return nil
+}
+
+func TestFileDeadLetterStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dead-letters")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	store, err := newFileDeadLetterStore(filepath.Join(dir, "nested"))
+	assert.NoError(t, err)
+
+	msg := queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`}
+	assert.NoError(t, store.store(msg, "first"))
+	assert.NoError(t, store.store(msg, "second"))
+
+	files, err := filepath.Glob(filepath.Join(dir, "nested", "dead-letters-*.ndjson"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	f, err := os.Open(files[0])
+	assert.NoError(t, err)
+	defer f.Close()
+
+	var reasons []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := deadLetterRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		assert.Equal(t, msg.Headers, record.Headers)
+		assert.Equal(t, msg.Body, record.Body)
+		reasons = append(reasons, record.Reason)
+	}
+	assert.Equal(t, []string{"first", "second"}, reasons)
+}
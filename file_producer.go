@@ -0,0 +1,190 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+const (
+	fileSinkPrefix     = "messages-"
+	fileSinkExtension  = ".ndjson"
+	fileSinkTimeLayout = "20060102T150405Z"
+)
+
+// fileSinkConfig holds the settings specific to the file producer. A file is rotated once rotateBytes
+// of messages have been written to it, or rotateInterval after it was opened; 0 disables either limit.
+type fileSinkConfig struct {
+	rotateBytes    int64
+	rotateInterval time.Duration
+	gzip           bool
+}
+
+// fileRecord is the line written for every message
+type fileRecord struct {
+	TransactionID string            `json:"tid"`
+	ReceivedAt    string            `json:"receivedAt"`
+	Headers       map[string]string `json:"headers"`
+	Body          string            `json:"body"`
+}
+
+// fileMessageProducer appends the messages as NDJSON to rotating files in a local directory, as an archive
+// of the bridged traffic or to capture a real stream for tests. Gzipped files are flushed after every message,
+// so that they can be read up to the last message even before they are rotated.
+type fileMessageProducer struct {
+	dir    string
+	config fileSinkConfig
+	now    func() time.Time
+
+	sync.Mutex
+	file     *os.File
+	gz       *gzip.Writer
+	written  int64
+	openedAt time.Time
+}
+
+func newFileMessageProducer(dir string, config fileSinkConfig) (*fileMessageProducer, error) {
+	if dir == "" {
+		return nil, errors.New("the file producer requires a directory")
+	}
+	if config.rotateBytes < 0 || config.rotateInterval < 0 {
+		return nil, errors.New("the rotation limits can't be negative")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &fileMessageProducer{dir: dir, config: config, now: time.Now}, nil
+}
+
+func (p *fileMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	line, err := json.Marshal(newFileRecord(message, p.now()))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	p.Lock()
+	defer p.Unlock()
+	if err := p.write(line); err != nil {
+		return fmt.Errorf("Writing message with tid: %s to file is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+	}
+	return nil
+}
+
+// newFileRecord archives the message, received now unless the bridge recorded when it was received
+func newFileRecord(message queueProducer.Message, now time.Time) fileRecord {
+	headers := messageHeaders(message.Headers)
+	receivedAt := headers.Get(receivedAtHeader)
+	if receivedAt == "" {
+		receivedAt = formatMessageTimestamp(now)
+	}
+	return fileRecord{
+		TransactionID: headers.Get("X-Request-Id"),
+		ReceivedAt:    receivedAt,
+		Headers:       message.Headers,
+		Body:          message.Body,
+	}
+}
+
+// ConnectivityCheck creates and removes a file in the directory, which fails when the disk is full or read only
+func (p *fileMessageProducer) ConnectivityCheck() (string, error) {
+	f, err := ioutil.TempFile(p.dir, ".healthcheck-")
+	if err != nil {
+		return "Forwarding messages is broken. Couldn't write to " + p.dir, err
+	}
+	f.Close()
+	os.Remove(f.Name())
+	return "The file sink directory is writable", nil
+}
+
+// Close finishes the current file
+func (p *fileMessageProducer) Close() error {
+	p.Lock()
+	defer p.Unlock()
+	return p.closeFile()
+}
+
+func (p *fileMessageProducer) write(line []byte) error {
+	if p.file != nil && p.rotationDue(len(line)) {
+		if err := p.closeFile(); err != nil {
+			return err
+		}
+	}
+	if p.file == nil {
+		if err := p.openFile(); err != nil {
+			return err
+		}
+	}
+
+	var w io.Writer = p.file
+	if p.gz != nil {
+		w = p.gz
+	}
+	n, err := w.Write(line)
+	p.written += int64(n)
+	if err != nil {
+		return err
+	}
+	if p.gz != nil {
+		return p.gz.Flush()
+	}
+	return nil
+}
+
+// rotationDue is true when the line would take the file over its size, or the file is older than the rotation interval.
+// A line larger than the size limit still gets a file of its own.
+func (p *fileMessageProducer) rotationDue(lineSize int) bool {
+	if p.config.rotateBytes > 0 && p.written > 0 && p.written+int64(lineSize) > p.config.rotateBytes {
+		return true
+	}
+	return p.config.rotateInterval > 0 && p.now().Sub(p.openedAt) >= p.config.rotateInterval
+}
+
+// openFile creates the next file, named after the time it was opened and numbered so that the names sort in write order
+func (p *fileMessageProducer) openFile() error {
+	now := p.now()
+	name := fileSinkPrefix + now.UTC().Format(fileSinkTimeLayout)
+	extension := fileSinkExtension
+	if p.config.gzip {
+		extension += ".gz"
+	}
+	for sequence := 0; ; sequence++ {
+		path := filepath.Join(p.dir, fmt.Sprintf("%s-%03d%s", name, sequence, extension))
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if os.IsExist(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		p.file, p.written, p.openedAt = file, 0, now
+		if p.config.gzip {
+			p.gz = gzip.NewWriter(file)
+		}
+		return nil
+	}
+}
+
+func (p *fileMessageProducer) closeFile() error {
+	if p.file == nil {
+		return nil
+	}
+	var err error
+	if p.gz != nil {
+		err = p.gz.Close()
+	}
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	p.file, p.gz = nil, nil
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+func readFileRecords(t *testing.T, path string) []fileRecord {
+	f, err := os.Open(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	var r io.Reader = f
+	if filepath.Ext(path) == ".gz" {
+		gz, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		r = gz
+	}
+	var records []fileRecord
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		record := fileRecord{}
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestFileProducerRotation(t *testing.T) {
+	message := queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_test", receivedAtHeader: "2026-10-19T01:00:00.000Z"},
+		Body:    `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+	}
+	line, _ := json.Marshal(fileRecord{TransactionID: "tid_test", ReceivedAt: "2026-10-19T01:00:00.000Z", Headers: message.Headers, Body: message.Body})
+	lineSize := int64(len(line) + 1)
+
+	var tests = []struct {
+		name     string
+		config   fileSinkConfig
+		step     time.Duration
+		expected []int
+	}{
+		{"size", fileSinkConfig{rotateBytes: 2 * lineSize}, 0, []int{2, 2, 1}},
+		{"interval", fileSinkConfig{rotateInterval: time.Minute}, 40 * time.Second, []int{2, 2, 1}},
+		{"no rotation", fileSinkConfig{}, time.Hour, []int{5}},
+		{"gzip", fileSinkConfig{rotateBytes: 3 * lineSize, gzip: true}, 0, []int{3, 2}},
+	}
+
+	for _, test := range tests {
+		dir := t.TempDir()
+		p, err := newFileMessageProducer(dir, test.config)
+		assert.NoError(t, err, test.name)
+		now := time.Date(2026, 10, 19, 1, 0, 0, 0, time.UTC)
+		p.now = func() time.Time { return now }
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, p.SendMessage("", message), test.name)
+			now = now.Add(test.step)
+		}
+
+		files, _ := filepath.Glob(filepath.Join(dir, fileSinkPrefix+"*"))
+		var counts []int
+		for _, file := range files {
+			records := readFileRecords(t, file)
+			counts = append(counts, len(records))
+			assert.Equal(t, "tid_test", records[0].TransactionID, test.name)
+			assert.Equal(t, "2026-10-19T01:00:00.000Z", records[0].ReceivedAt, test.name)
+			assert.Equal(t, message.Body, records[0].Body, test.name)
+		}
+		assert.Equal(t, test.expected, counts, test.name)
+		assert.NoError(t, p.Close(), test.name)
+	}
+}
+
+func TestFileProducerFileNames(t *testing.T) {
+	dir := t.TempDir()
+	p, err := newFileMessageProducer(dir, fileSinkConfig{rotateBytes: 1, gzip: true})
+	assert.NoError(t, err)
+	p.now = func() time.Time { return time.Date(2026, 10, 19, 1, 2, 3, 0, time.UTC) }
+
+	message := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{}`}
+	assert.NoError(t, p.SendMessage("", message))
+	assert.NoError(t, p.SendMessage("", message))
+	assert.NoError(t, p.Close())
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "messages-20261019T010203Z-000.ndjson.gz"),
+		filepath.Join(dir, "messages-20261019T010203Z-001.ndjson.gz"),
+	}, files)
+	records := readFileRecords(t, files[0])
+	assert.Equal(t, "2026-10-19T01:02:03.000Z", records[0].ReceivedAt, "The write time is used without a received-at header")
+}
+
+func TestFileProducerConnectivityCheck(t *testing.T) {
+	dir := t.TempDir()
+	p, err := newFileMessageProducer(dir, fileSinkConfig{})
+	assert.NoError(t, err)
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Empty(t, files, "The healthcheck file is removed")
+
+	p.dir = filepath.Join(dir, "missing")
+	_, err = p.ConnectivityCheck()
+	assert.Error(t, err)
+
+	_, err = newFileMessageProducer("", fileSinkConfig{})
+	assert.Error(t, err)
+}
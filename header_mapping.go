@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// headerMapping declares which message headers become request headers, and under which name.
+// The rules are applied in the following order: pass-through (minus the exclusions), keep, copy, rename and static values.
+// Dropped headers are ignored by every rule. Headers with empty values are never forwarded.
+type headerMapping struct {
+	// PassThroughAll forwards every header not listed in Exclude under its own name
+	PassThroughAll bool     `json:"passThroughAll"`
+	Exclude        []string `json:"exclude"`
+	// Keep forwards the listed headers under their own name
+	Keep []string `json:"keep"`
+	// Copy forwards the header under both its own and the new name
+	Copy map[string]string `json:"copy"`
+	// Rename forwards the header only under the new name
+	Rename map[string]string `json:"rename"`
+	Drop   []string          `json:"drop"`
+	// Static sets fixed header values on every request
+	Static map[string]string `json:"static"`
+}
+
+// defaultHeaderMapping is the mapping historically hardcoded in the plainHTTP producer
+var defaultHeaderMapping = headerMapping{
+	Keep: []string{"X-Request-Id", "Message-Timestamp", "X-Schema-Version", "Content-Type"},
+	Rename: map[string]string{
+		"Origin-System-Id": "X-Origin-System-Id",
+		"Native-Hash":      "X-Native-Hash",
+	},
+}
+
+// parseHeaderMapping parses a JSON header mapping, falling back to the default mapping when none is provided
+func parseHeaderMapping(spec string) (*headerMapping, error) {
+	if strings.TrimSpace(spec) == "" {
+		mapping := defaultHeaderMapping
+		return &mapping, nil
+	}
+
+	mapping := &headerMapping{}
+	if err := json.Unmarshal([]byte(spec), mapping); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// withKeep returns a copy of the mapping which additionally keeps the given headers
+func (m *headerMapping) withKeep(names ...string) *headerMapping {
+	extended := *m
+	extended.Keep = append(append([]string{}, m.Keep...), names...)
+	return &extended
+}
+
+// apply returns the headers to send according to the mapping. Header names are unique whatever their case,
+// the name set by the last rule winning, so that preserving the case doesn't send a header twice.
+func (m *headerMapping) apply(headers map[string]string) map[string]string {
+	dropped := toHeaderSet(m.Drop)
+	lookup := func(name string) (string, bool) {
+		if dropped[canonicalHeaderName(name)] {
+			return "", false
+		}
+		value, found := messageHeaders(headers).Lookup(name)
+		return value, found && value != ""
+	}
+
+	mapped := make(messageHeaders)
+	if m.PassThroughAll {
+		excluded := toHeaderSet(m.Exclude)
+		for name := range headers {
+			if value, found := lookup(name); found && !excluded[canonicalHeaderName(name)] {
+				mapped.Set(name, value)
+			}
+		}
+	}
+	for _, name := range m.Keep {
+		if value, found := lookup(name); found {
+			mapped.Set(name, value)
+		}
+	}
+	for from, to := range m.Copy {
+		if value, found := lookup(from); found {
+			mapped.Set(from, value)
+			mapped.Set(to, value)
+		}
+	}
+	for from, to := range m.Rename {
+		if value, found := lookup(from); found {
+			mapped.Del(from)
+			mapped.Set(to, value)
+		}
+	}
+	for name, value := range m.Static {
+		mapped.Set(name, value)
+	}
+	return map[string]string(mapped)
+}
+
+// toHeaderSet returns the set of the canonical header names
+func toHeaderSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[canonicalHeaderName(name)] = true
+	}
+	return set
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var mappingTestHeaders = map[string]string{
+	"Message-Id":        "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+	"Message-Timestamp": "2015-07-06T07:03:09.362Z",
+	"Message-Type":      "cms-content-published",
+	"Origin-System-Id":  "http://cmdb.ft.com/systems/methode-web-pub",
+	"Content-Type":      "application/json",
+	"X-Request-Id":      "tid_t9happe59y",
+	"Native-Hash":       "",
+}
+
+func TestHeaderMappingApply(t *testing.T) {
+	var tests = []struct {
+		name     string
+		spec     string
+		expected map[string]string
+	}{
+		{
+			"default profile",
+			"",
+			map[string]string{
+				"X-Request-Id":       "tid_t9happe59y",
+				"Message-Timestamp":  "2015-07-06T07:03:09.362Z",
+				"Content-Type":       "application/json",
+				"X-Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub",
+			},
+		},
+		{
+			"pass-through with exclusions",
+			`{"passThroughAll":true,"exclude":["Message-Timestamp","Content-Type"]}`,
+			map[string]string{
+				"Message-Id":       "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+				"Message-Type":     "cms-content-published",
+				"Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub",
+				"X-Request-Id":     "tid_t9happe59y",
+			},
+		},
+		{
+			"copy, rename, drop and static",
+			`{"keep":["X-Request-Id","Message-Type"],"copy":{"Message-Id":"X-Message-Id"},"rename":{"Content-Type":"X-Content-Type"},"drop":["Message-Type"],"static":{"X-Bridge":"kafka-bridge"}}`,
+			map[string]string{
+				"X-Request-Id":   "tid_t9happe59y",
+				"Message-Id":     "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+				"X-Message-Id":   "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+				"X-Content-Type": "application/json",
+				"X-Bridge":       "kafka-bridge",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		mapping, err := parseHeaderMapping(test.spec)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, mapping.apply(mappingTestHeaders), test.name)
+	}
+}
+
+func TestHeaderMappingRenameIgnoresCase(t *testing.T) {
+	mapping, err := parseHeaderMapping(`{"passThroughAll":true,"rename":{"X-Request-Id":"X-Transaction-Id"}}`)
+	assert.NoError(t, err)
+
+	mapped := mapping.apply(map[string]string{"x-request-id": "tid_t9happe59y", "Message-Type": "cms-content-published"})
+	assert.Equal(t, map[string]string{"X-Transaction-Id": "tid_t9happe59y", "Message-Type": "cms-content-published"}, mapped)
+}
+
+func TestHeaderMappingPreservedCaseIsUnique(t *testing.T) {
+	mapping, err := parseHeaderMapping(`{"passThroughAll":true,"keep":["X-Request-Id"],"copy":{"message-type":"X-Message-Type"},"static":{"content-type":"application/json"}}`)
+	assert.NoError(t, err)
+
+	mapped := mapping.apply(map[string]string{"x-request-id": "tid_t9happe59y", "Message-Type": "cms-content-published", "Content-Type": "text/plain"})
+	assert.Equal(t, map[string]string{
+		"X-Request-Id":   "tid_t9happe59y",
+		"message-type":   "cms-content-published",
+		"X-Message-Type": "cms-content-published",
+		"content-type":   "application/json",
+	}, mapped)
+}
+
+func TestHeaderMappingWithKeep(t *testing.T) {
+	mapping, err := parseHeaderMapping("")
+	assert.NoError(t, err)
+
+	extended := mapping.withKeep("Message-Type")
+	assert.Equal(t, "cms-content-published", extended.apply(mappingTestHeaders)["Message-Type"])
+	assert.NotContains(t, mapping.apply(mappingTestHeaders), "Message-Type", "The original mapping must not be modified")
+	assert.NotContains(t, defaultHeaderMapping.Keep, "Message-Type")
+}
+
+func TestParseHeaderMappingInvalid(t *testing.T) {
+	_, err := parseHeaderMapping(`{"keep":"X-Request-Id"}`)
+	assert.Error(t, err)
+}
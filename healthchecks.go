@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
@@ -17,6 +18,8 @@ type HealthCheck struct {
 	consumer     consumer.MessageConsumer
 	producer     producer.MessageProducer
 	producerType string
+	sourceType   string
+	maintenance  *maintenanceSchedule
 }
 
 func NewHealthCheck(consumerConf *consumer.QueueConfig, p producer.MessageProducer, producerType string, client *http.Client) *HealthCheck {
@@ -35,10 +38,39 @@ func (hc HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
 		hc.consumeHealthcheck(), hc.httpForwarderHealthcheck(),
 	}
 
-	if hc.producerType == proxy {
+	switch hc.producerType {
+	case proxy, confluentREST:
 		description = "Services: source-kafka-proxy, destination-kafka-proxy"
 		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.proxyForwarderHealthcheck()}
-
+	case kafka:
+		description = "Services: source-kafka-proxy, destination-kafka-brokers"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.kafkaForwarderHealthcheck()}
+	case fileSink:
+		description = "Services: source-kafka-proxy, file-sink"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.fileSinkHealthcheck()}
+	case s3Sink:
+		description = "Services: source-kafka-proxy, s3-sink"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.s3SinkHealthcheck()}
+	case natsJetStream:
+		description = "Services: source-kafka-proxy, destination-nats"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.natsForwarderHealthcheck()}
+	case redisStream:
+		description = "Services: source-kafka-proxy, destination-redis"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.redisForwarderHealthcheck()}
+	case amqpExchange:
+		description = "Services: source-kafka-proxy, destination-rabbitmq"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.amqpForwarderHealthcheck()}
+	case webhook:
+		description = "Services: source-kafka-proxy, webhook-endpoints"
+		checks = []fthealth.Check{hc.consumeHealthcheck(), hc.webhookHealthcheck()}
+	}
+	switch hc.sourceType {
+	case kafka:
+		description = strings.Replace(description, "source-kafka-proxy", "source-kafka-brokers", 1)
+	case natsJetStream:
+		description = strings.Replace(description, "source-kafka-proxy", "source-nats", 1)
+	case redisStream:
+		description = strings.Replace(description, "source-kafka-proxy", "source-redis", 1)
 	}
 
 	healthCheck := fthealth.TimedHealthCheck{
@@ -55,13 +87,42 @@ func (hc HealthCheck) Health() func(w http.ResponseWriter, r *http.Request) {
 }
 
 func (hc HealthCheck) consumeHealthcheck() fthealth.Check {
+	switch hc.sourceType {
+	case kafka:
+		return fthealth.Check{
+			BusinessImpact:   "Consuming messages from the source kafka won't work. Publishing in the containerised stack won't work.",
+			Name:             "Consume messages from the kafka brokers",
+			PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+			Severity:         1,
+			TechnicalSummary: "Consuming messages is broken. Check if the source brokers are reachable and the topic exists.",
+			Checker:          hc.unlessPaused(hc.consumer.ConnectivityCheck),
+		}
+	case natsJetStream:
+		return fthealth.Check{
+			BusinessImpact:   "Consuming messages from NATS JetStream won't work. Publishing in the containerised stack won't work.",
+			Name:             "Consume messages from NATS JetStream",
+			PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+			Severity:         1,
+			TechnicalSummary: "Consuming messages is broken. Check if the NATS servers are reachable and the stream and its consumer exist.",
+			Checker:          hc.unlessPaused(hc.consumer.ConnectivityCheck),
+		}
+	case redisStream:
+		return fthealth.Check{
+			BusinessImpact:   "Consuming messages from the Redis Stream won't work.",
+			Name:             "Consume messages from the Redis Stream",
+			PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+			Severity:         2,
+			TechnicalSummary: "Consuming messages is broken. Check if the redis server is reachable and the consumer group of the stream exists.",
+			Checker:          hc.unlessPaused(hc.consumer.ConnectivityCheck),
+		}
+	}
 	return fthealth.Check{
 		BusinessImpact:   "Consuming messages through kafka-proxy won't work. Publishing in the containerised stack won't work.",
 		Name:             "Consume messages from kafka-proxy",
 		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
 		Severity:         1,
 		TechnicalSummary: "Consuming messages is broken. Check if source proxy is reachable.",
-		Checker:          hc.consumer.ConnectivityCheck,
+		Checker:          hc.unlessPaused(hc.consumer.ConnectivityCheck),
 	}
 }
 
@@ -72,7 +133,84 @@ func (hc HealthCheck) proxyForwarderHealthcheck() fthealth.Check {
 		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
 		Severity:         1,
 		TechnicalSummary: "Forwarding messages is broken. Check if destination proxy is reachable.",
-		Checker:          hc.producer.ConnectivityCheck,
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) kafkaForwarderHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Forwarding messages to the destination kafka in coco won't work. Publishing in the containerised stack won't work.",
+		Name:             "Forward messages to the kafka brokers",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         1,
+		TechnicalSummary: "Forwarding messages is broken. Check if the destination brokers are reachable and the topic exists.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) fileSinkHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Archiving the bridged messages to local files won't work.",
+		Name:             "Write messages to the file sink",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Writing messages is broken. Check if the file sink directory exists, is writable and the disk isn't full.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) s3SinkHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Archiving the bridged messages to object storage for audit won't work.",
+		Name:             "Write messages to the S3 bucket",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Writing messages is broken. Check if the S3 endpoint is reachable and the credentials allow writing to the bucket.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) webhookHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Pushing the bridged messages to the webhook consumers won't work.",
+		Name:             "Push messages to the webhook endpoints",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Forwarding messages is broken. Check if the webhook endpoints are reachable.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) natsForwarderHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Forwarding messages to NATS JetStream won't work. The internal tooling on NATS won't receive publications.",
+		Name:             "Forward messages to NATS JetStream",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Forwarding messages is broken. Check if the NATS servers are reachable and a stream captures the subject.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) redisForwarderHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Forwarding messages to the Redis Stream won't work. The local consumers won't receive publications.",
+		Name:             "Forward messages to the Redis Stream",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Forwarding messages is broken. Check if the redis server is reachable and the stream key holds a stream.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
+	}
+}
+
+func (hc HealthCheck) amqpForwarderHealthcheck() fthealth.Check {
+	return fthealth.Check{
+		BusinessImpact:   "Forwarding messages to RabbitMQ won't work. The legacy consumers on RabbitMQ won't receive publications.",
+		Name:             "Forward messages to the RabbitMQ exchange",
+		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
+		Severity:         2,
+		TechnicalSummary: "Forwarding messages is broken. Check if the RabbitMQ broker is reachable and the exchange exists.",
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
 	}
 }
 
@@ -83,17 +221,17 @@ func (hc HealthCheck) httpForwarderHealthcheck() fthealth.Check {
 		PanicGuide:       fmt.Sprintf("https://runbooks.ftops.tech/%s", systemCode),
 		Severity:         1,
 		TechnicalSummary: "Forwarding messages is broken. Check networking, cluster reachability and/or cms-notifier state.",
-		Checker:          hc.producer.ConnectivityCheck,
+		Checker:          hc.unlessPaused(hc.producer.ConnectivityCheck),
 	}
 }
 
 func (hc HealthCheck) GTG() gtg.Status {
 	consumerCheck := func() gtg.Status {
-		return gtgCheck(hc.consumer.ConnectivityCheck)
+		return gtgCheck(hc.unlessPaused(hc.consumer.ConnectivityCheck))
 	}
 
 	producerCheck := func() gtg.Status {
-		return gtgCheck(hc.producer.ConnectivityCheck)
+		return gtgCheck(hc.unlessPaused(hc.producer.ConnectivityCheck))
 	}
 
 	return gtg.FailFastParallelCheck([]gtg.StatusChecker{
@@ -108,3 +246,13 @@ func gtgCheck(handler func() (string, error)) gtg.Status {
 	}
 	return gtg.Status{GoodToGo: true}
 }
+
+// unlessPaused reports the bridge as paused rather than unhealthy while a maintenance window is in progress
+func (hc HealthCheck) unlessPaused(check func() (string, error)) func() (string, error) {
+	return func() (string, error) {
+		if status, paused := hc.maintenance.pausedStatus(time.Now()); paused {
+			return status, nil
+		}
+		return check()
+	}
+}
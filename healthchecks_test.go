@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	fthealth "github.com/Financial-Times/go-fthealth/v1_1"
 	"github.com/Financial-Times/message-queue-go-producer/producer"
@@ -151,6 +152,28 @@ func TestHealthBrokenPlainHTTPProducer(t *testing.T) {
 	}
 }
 
+func TestHealthBrokenKafkaProducer(t *testing.T) {
+	hc := initializeHealthcheck(false, true, kafka)
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+	endpoint := hc.Health()
+
+	endpoint(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, "HealthCheck should return 200")
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+	assert.Len(t, checks, 2)
+
+	for _, check := range checks {
+		if check.Name == "Forward messages to the kafka brokers" {
+			assert.False(t, check.Ok)
+		} else {
+			assert.True(t, check.Ok)
+		}
+	}
+}
+
 func TestHealthBrokenConsumer(t *testing.T) {
 	hc := initializeHealthcheck(true, false, proxy)
 
@@ -180,3 +203,23 @@ func parseHealthcheck(healthcheckJSON string) ([]fthealth.CheckResult, error) {
 	err := json.Unmarshal([]byte(healthcheckJSON), result)
 	return result.Checks, err
 }
+
+func TestHealthPausedForMaintenance(t *testing.T) {
+	hc := initializeHealthcheck(false, false, proxy)
+	now := time.Now().UTC()
+	hc.maintenance = &maintenanceSchedule{windows: []maintenanceWindow{{start: now.Add(-time.Minute), end: now.Add(time.Hour)}}}
+
+	status := hc.GTG()
+	assert.True(t, status.GoodToGo)
+
+	req := httptest.NewRequest("GET", "http://example.com/__health", nil)
+	w := httptest.NewRecorder()
+	hc.Health()(w, req)
+	checks, err := parseHealthcheck(w.Body.String())
+	assert.NoError(t, err)
+
+	for _, check := range checks {
+		assert.True(t, check.Ok)
+		assert.Contains(t, check.CheckOutput, "Paused for maintenance until")
+	}
+}
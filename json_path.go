@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// decodeJSON decodes a document keeping numbers as json.Number, so that they are re-encoded untouched
+func decodeJSON(doc []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(doc))
+	decoder.UseNumber()
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// encodeJSON encodes a document without escaping HTML characters, which are common in content bodies
+func encodeJSON(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// getJSONPath returns the value found at the dotted path
+func getJSONPath(doc interface{}, path string) (interface{}, bool) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = obj[key]; !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setJSONPath sets the value at the dotted path, creating the missing intermediate objects
+func setJSONPath(doc interface{}, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	current, ok := doc.(map[string]interface{})
+	if !ok {
+		return errors.New("the document is not a JSON object")
+	}
+	for _, key := range keys[:len(keys)-1] {
+		next, found := current[key]
+		if !found {
+			next = make(map[string]interface{})
+			current[key] = next
+		}
+		if current, ok = next.(map[string]interface{}); !ok {
+			return errors.New("field " + key + " of path " + path + " is not a JSON object")
+		}
+	}
+	current[keys[len(keys)-1]] = value
+	return nil
+}
+
+// removeJSONPath removes the field at the dotted path, returning whether it was present
+func removeJSONPath(doc interface{}, path string) bool {
+	keys := strings.Split(path, ".")
+	parent := doc
+	if len(keys) > 1 {
+		var found bool
+		if parent, found = getJSONPath(doc, strings.Join(keys[:len(keys)-1], ".")); !found {
+			return false
+		}
+	}
+	obj, ok := parent.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	if _, found := obj[keys[len(keys)-1]]; !found {
+		return false
+	}
+	delete(obj, keys[len(keys)-1])
+	return true
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -23,14 +25,60 @@ type BridgeApp struct {
 	producerInstance producer.MessageProducer
 	producerType     string
 	httpClient       *http.Client
+	producerOptions  producerOptions
+	shadow           *shadowProducer
+	maintenance      *maintenanceSchedule
+	priorityLanes    *priorityLanes
+	bodyTransforms   *bodyTransformPipeline
+	schemaValidator  *schemaValidator
+	deadLetters      deadLetterStore
+	provenance       *provenance
+	claimCheck       *claimCheck
+	redaction        *redactionRules
+	cloudEvents      *cloudEventsEncoder
+	tidValidation    *tidValidation
+	timestamps       timestampPolicy
+	// source replaces the kafka proxy consumer when consuming natively, e.g. from the kafka brokers
+	source     messageSource
+	sourceType string
+	// preserveHeaderCase keeps the header names as consumed instead of canonicalising them
+	preserveHeaderCase bool
 }
 
 const (
 	plainHTTP = "plainHTTP"
 	proxy     = "proxy"
+	kafka     = "kafka"
+	// confluentREST produces through the records API of a Confluent REST Proxy
+	confluentREST = "confluentRest"
+	// fileSink appends the messages to local NDJSON files
+	fileSink = "file"
+	// s3Sink archives the messages in an S3-compatible bucket
+	s3Sink = "s3"
+	// webhook pushes signed messages to one or more URLs
+	webhook = "webhook"
+	// natsJetStream publishes to, or consumes from, NATS JetStream
+	natsJetStream = "nats"
+	// amqpExchange publishes to a RabbitMQ exchange
+	amqpExchange = "amqp"
+	// redisStream appends to, or reads from, a Redis Stream
+	redisStream = "redis"
 )
 
-func newBridgeApp(consumerAddrs string, consumerGroupID string, consumerOffset string, consumerAutoCommitEnable bool, consumerAuthorizationKey string, topic string, producerAddress string, producerAuth string, producerType string) *BridgeApp {
+// producerOptions holds the settings specific to each producer type
+type producerOptions struct {
+	plainHTTP plainHTTPConfig
+	kafka     kafkaProducerConfig
+	rest      confluentRESTConfig
+	file      fileSinkConfig
+	s3        s3Config
+	webhook   webhookConfig
+	nats      natsProducerConfig
+	amqp      amqpProducerConfig
+	redis     redisProducerConfig
+}
+
+func newBridgeApp(consumerAddrs string, consumerGroupID string, consumerOffset string, consumerAutoCommitEnable bool, consumerAuthorizationKey string, topic string, producerAddress string, producerAuth string, producerType string, producerOpts producerOptions) *BridgeApp {
 	consumerConfig := consumer.QueueConfig{}
 	consumerConfig.Addrs = strings.Split(consumerAddrs, ",")
 	consumerConfig.Group = consumerGroupID
@@ -44,15 +92,7 @@ func newBridgeApp(consumerAddrs string, consumerGroupID string, consumerOffset s
 	producerConfig.Topic = topic
 	producerConfig.Authorization = producerAuth
 
-	var producerInstance producer.MessageProducer
-	switch producerType {
-	case proxy:
-		producerInstance = producer.NewMessageProducer(producerConfig)
-	case plainHTTP:
-		producerInstance = newPlainHTTPMessageProducer(producerConfig)
-	default:
-		logger.Fatalf(nil, fmt.Errorf("Unknown producer type %s", producerType), "The provided producer type '%v' is invalid", producerType)
-	}
+	producerInstance := newMessageProducer(producerType, producerConfig, producerOpts)
 
 	httpClient := &http.Client{
 		Timeout: 60 * time.Second,
@@ -68,15 +108,143 @@ func newBridgeApp(consumerAddrs string, consumerGroupID string, consumerOffset s
 		producerConfig:   &producerConfig,
 		producerInstance: producerInstance,
 		producerType:     producerType,
+		producerOptions:  producerOpts,
 		httpClient:       httpClient,
 	}
 	return bridgeApp
 }
 
+func newMessageProducer(producerType string, producerConfig producer.MessageProducerConfig, producerOpts producerOptions) producer.MessageProducer {
+	var producerInstance producer.MessageProducer
+	switch producerType {
+	case proxy:
+		producerInstance = producer.NewMessageProducer(producerConfig)
+	case plainHTTP:
+		producerInstance = newPlainHTTPMessageProducer(producerConfig, producerOpts.plainHTTP)
+	case confluentREST:
+		var err error
+		producerInstance, err = newConfluentRESTMessageProducer(producerConfig, producerOpts.rest)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided REST proxy settings are invalid")
+		}
+	case kafka:
+		var err error
+		producerInstance, err = newKafkaMessageProducer(producerConfig.Addr, producerConfig.Topic, producerOpts.kafka)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the kafka producer")
+		}
+	case fileSink:
+		var err error
+		producerInstance, err = newFileMessageProducer(producerConfig.Addr, producerOpts.file)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the file producer")
+		}
+	case s3Sink:
+		var err error
+		producerInstance, err = newS3MessageProducer(producerConfig.Addr, producerConfig.Topic, producerOpts.s3)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the S3 producer")
+		}
+	case webhook:
+		endpoints, err := parseWebhookEndpoints(producerOpts.webhook.endpoints, producerConfig.Addr, producerOpts.webhook.secret)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided webhook endpoints are invalid")
+		}
+		producerInstance = newWebhookMessageProducer(endpoints)
+	case amqpExchange:
+		config := producerOpts.amqp
+		if config.routingKey == "" {
+			config.routingKey = producerConfig.Topic
+		}
+		var err error
+		producerInstance, err = newAMQPMessageProducer(producerConfig.Addr, config)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the AMQP producer")
+		}
+	case redisStream:
+		config := producerOpts.redis
+		if config.stream == "" {
+			config.stream = producerConfig.Topic
+		}
+		var err error
+		producerInstance, err = newRedisStreamProducer(producerConfig.Addr, config)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the redis producer")
+		}
+	case natsJetStream:
+		subject := producerOpts.nats.subject
+		if subject == "" {
+			subject = producerConfig.Topic
+		}
+		var err error
+		producerInstance, err = newNATSMessageProducer(producerConfig.Addr, subject, producerOpts.nats.client)
+		if err != nil {
+			logger.Fatalf(nil, err, "Couldn't create the NATS producer")
+		}
+	default:
+		logger.Fatalf(nil, fmt.Errorf("Unknown producer type %s", producerType), "The provided producer type '%v' is invalid", producerType)
+	}
+	return producerInstance
+}
+
+// enableShadowProducer wraps the primary producer so that every message is also sent to a shadow destination.
+// Only the primary producer's outcome is reported back to the forwarder.
+func (bridgeApp *BridgeApp) enableShadowProducer(shadowAddress string, shadowAuth string, shadowType string, latencyTolerance string, timeout string, maxInFlight int) {
+	tolerance, err := time.ParseDuration(latencyTolerance)
+	if err != nil {
+		logger.Fatalf(nil, err, "The provided shadow latency tolerance is invalid")
+	}
+	shadowTimeout, err := time.ParseDuration(timeout)
+	if err != nil || shadowTimeout <= 0 {
+		logger.Fatalf(nil, err, "The provided shadow timeout is invalid")
+	}
+	if maxInFlight < 1 {
+		logger.Fatalf(nil, errors.New("at least one shadow send must be allowed"), "The provided shadow max in flight is invalid")
+	}
+
+	shadowConfig := producer.MessageProducerConfig{
+		Addr:          shadowAddress,
+		Topic:         bridgeApp.producerConfig.Topic,
+		Authorization: shadowAuth,
+	}
+	bridgeApp.shadow = newShadowProducer(bridgeApp.producerInstance, newMessageProducer(shadowType, shadowConfig, bridgeApp.producerOptions), tolerance, shadowTimeout, maxInFlight)
+	bridgeApp.producerInstance = bridgeApp.shadow
+	logger.Infof(nil, "Shadow producer enabled, forwarding a copy of every message to "+shadowAddress)
+}
+
+// enableBatching wraps the producer so that messages are sent to the destination in batches.
+// The forwarder hands the messages of a consumed batch over without waiting, then waits for every outcome before committing.
+func (bridgeApp *BridgeApp) enableBatching(maxMessages int, maxBytes int, linger string) {
+	lingerTime, err := time.ParseDuration(linger)
+	if err != nil {
+		logger.Fatalf(nil, err, "The provided batch linger time is invalid")
+	}
+	batching, err := newBatchingProducer(bridgeApp.producerInstance, batchingConfig{maxMessages: maxMessages, maxBytes: maxBytes, linger: lingerTime})
+	if err != nil {
+		logger.Fatalf(nil, err, "The provided batch settings are invalid for producer type %v", bridgeApp.producerType)
+	}
+	bridgeApp.producerInstance = batching
+	logger.Infof(map[string]interface{}{"maxMessages": maxMessages, "maxBytes": maxBytes, "linger": linger}, "Batch publishing enabled")
+}
+
 func (bridgeApp *BridgeApp) enableHealthchecksAndGTG() {
 	hc := NewHealthCheck(bridgeApp.consumerConfig, bridgeApp.producerInstance, bridgeApp.producerType, bridgeApp.httpClient)
+	hc.maintenance = bridgeApp.maintenance
+	if bridgeApp.source != nil {
+		hc.consumer = bridgeApp.source.consumer()
+		hc.sourceType = bridgeApp.sourceType
+	}
+	if kafkaSource, ok := bridgeApp.source.(*kafkaGroupSource); ok {
+		http.HandleFunc(partitionsPath, kafkaSource.partitionsHandler)
+	}
 	http.HandleFunc("/__health", hc.Health())
 	http.HandleFunc(httphandlers.GTGPath, httphandlers.NewGoodToGoHandler(hc.GTG))
+	if bridgeApp.shadow != nil {
+		http.HandleFunc(shadowReportPath, bridgeApp.shadow.reportHandler)
+	}
+	if bridgeApp.schemaValidator != nil {
+		http.HandleFunc(schemaValidationPath, bridgeApp.schemaValidator.statsHandler)
+	}
 
 	err := http.ListenAndServe(":8080", nil)
 	if err != nil {
@@ -126,6 +294,30 @@ func main() {
 		Desc:   "Kafka topic.",
 		EnvVar: "TOPIC",
 	})
+	sourceType := app.String(cli.StringOpt{
+		Name:   "source_type",
+		Value:  proxy,
+		Desc:   "Where the messages are consumed from: proxy - through the kafka-proxy hosts; kafka to join the consumer group straight against the brokers; nats to consume a JetStream stream; or redis to read a Redis Stream.",
+		EnvVar: "SOURCE_TYPE",
+	})
+	kafkaSourceBrokers := app.String(cli.StringOpt{
+		Name:   "kafka_source_brokers",
+		Value:  "",
+		Desc:   "Comma separated kafka brokers consumed from by the kafka source.",
+		EnvVar: "KAFKA_SOURCE_BROKERS",
+	})
+	kafkaSourceCommitInterval := app.String(cli.StringOpt{
+		Name:   "kafka_source_commit_interval",
+		Value:  "1s",
+		Desc:   "How often the kafka source commits the offsets of the forwarded messages. They are also committed at every rebalance.",
+		EnvVar: "KAFKA_SOURCE_COMMIT_INTERVAL",
+	})
+	kafkaSourceRebalanceStrategy := app.String(cli.StringOpt{
+		Name:   "kafka_source_rebalance_strategy",
+		Value:  "range",
+		Desc:   "How the kafka source group assigns the partitions: `range`, `roundrobin` or `sticky`.",
+		EnvVar: "KAFKA_SOURCE_REBALANCE_STRATEGY",
+	})
 	producerAddress := app.String(cli.StringOpt{
 		Name:   "producer_address",
 		Value:  "",
@@ -141,9 +333,453 @@ func main() {
 	producerType := app.String(cli.StringOpt{
 		Name:   "producer_type",
 		Value:  proxy,
-		Desc:   "Two possible values are accepted: proxy - if the requests are going through the kafka-proxy; or plainHTTP if a normal http request is required.",
+		Desc:   "Ten possible values are accepted: proxy - if the requests are going through the kafka-proxy; confluentRest for a Confluent REST Proxy; plainHTTP if a normal http request is required; kafka to write straight to the brokers listed in producer_address; file to append the messages to NDJSON files in the producer_address directory; s3 to write them to the producer_address bucket; webhook to push them as signed JSON to one or more URLs; nats to publish them to the JetStream servers listed in producer_address; amqp to publish them to an exchange of the producer_address RabbitMQ broker; or redis to append them to a stream of the producer_address Redis server.",
 		EnvVar: "PRODUCER_TYPE",
 	})
+	restAPIVersion := app.String(cli.StringOpt{
+		Name:   "rest_proxy_api_version",
+		Value:  confluentRESTv2,
+		Desc:   "Records API version of the Confluent REST Proxy: `v2` or `v3`.",
+		EnvVar: "REST_PROXY_API_VERSION",
+	})
+	restEmbeddedFormat := app.String(cli.StringOpt{
+		Name:   "rest_proxy_embedded_format",
+		Value:  embeddedFormatBinary,
+		Desc:   "Embedded format of the records sent to the Confluent REST Proxy: `binary` (the FT message) or `json` (the JSON body).",
+		EnvVar: "REST_PROXY_EMBEDDED_FORMAT",
+	})
+	restClusterID := app.String(cli.StringOpt{
+		Name:   "rest_proxy_cluster_id",
+		Value:  "",
+		Desc:   "Kafka cluster id, required by the v3 API of the Confluent REST Proxy.",
+		EnvVar: "REST_PROXY_CLUSTER_ID",
+	})
+	natsSubject := app.String(cli.StringOpt{
+		Name:   "nats_subject",
+		Value:  "",
+		Desc:   "JetStream subject the nats producer publishes to, defaults to the topic.",
+		EnvVar: "NATS_SUBJECT",
+	})
+	natsCredsFile := app.String(cli.StringOpt{
+		Name:   "nats_creds_file",
+		Value:  "",
+		Desc:   "NATS user credentials file, shared by the nats producer and source.",
+		EnvVar: "NATS_CREDS_FILE",
+	})
+	natsSourceServers := app.String(cli.StringOpt{
+		Name:   "nats_source_servers",
+		Value:  "",
+		Desc:   "Comma separated NATS servers consumed by the nats source.",
+		EnvVar: "NATS_SOURCE_SERVERS",
+	})
+	natsSourceStream := app.String(cli.StringOpt{
+		Name:   "nats_source_stream",
+		Value:  "",
+		Desc:   "JetStream stream consumed by the nats source, through a durable consumer named after the group id.",
+		EnvVar: "NATS_SOURCE_STREAM",
+	})
+	natsSourceSubject := app.String(cli.StringOpt{
+		Name:   "nats_source_subject",
+		Value:  "",
+		Desc:   "Subject filter of the nats source consumer. Empty consumes the whole stream.",
+		EnvVar: "NATS_SOURCE_SUBJECT",
+	})
+	redisStreamName := app.String(cli.StringOpt{
+		Name:   "redis_stream",
+		Value:  "",
+		Desc:   "Redis Stream the redis producer appends to. Defaults to the topic.",
+		EnvVar: "REDIS_STREAM",
+	})
+	redisMaxLen := app.Int(cli.IntOpt{
+		Name:   "redis_max_len",
+		Value:  100000,
+		Desc:   "Approximate number of entries the redis producer trims the stream to. 0 disables trimming.",
+		EnvVar: "REDIS_MAX_LEN",
+	})
+	redisSourceAddress := app.String(cli.StringOpt{
+		Name:   "redis_source_address",
+		Value:  "",
+		Desc:   "Redis server consumed by the redis source, as a redis:// URL or host:port.",
+		EnvVar: "REDIS_SOURCE_ADDRESS",
+	})
+	redisSourceStream := app.String(cli.StringOpt{
+		Name:   "redis_source_stream",
+		Value:  "",
+		Desc:   "Redis Stream read by the redis source, through a consumer group named after the group id.",
+		EnvVar: "REDIS_SOURCE_STREAM",
+	})
+	redisSourceConsumer := app.String(cli.StringOpt{
+		Name:   "redis_source_consumer",
+		Value:  "",
+		Desc:   "Name of the bridge instance in the redis consumer group. Defaults to the hostname, it must be stable across restarts for the unacknowledged entries to be forwarded again.",
+		EnvVar: "REDIS_SOURCE_CONSUMER",
+	})
+	amqpExchangeName := app.String(cli.StringOpt{
+		Name:   "amqp_exchange",
+		Value:  "",
+		Desc:   "Exchange the amqp producer publishes to.",
+		EnvVar: "AMQP_EXCHANGE",
+	})
+	amqpRoutingKey := app.String(cli.StringOpt{
+		Name:   "amqp_routing_key",
+		Value:  "",
+		Desc:   "Routing key of the published messages, with placeholders resolved from each message, e.g. `upp.{header:Message-Type}`. Defaults to the topic.",
+		EnvVar: "AMQP_ROUTING_KEY",
+	})
+	amqpHeaderMapping := app.String(cli.StringOpt{
+		Name:   "amqp_header_mapping",
+		Value:  "",
+		Desc:   "JSON mapping of message headers to AMQP headers, with the rules of plain_http_header_mapping. Every header is forwarded when empty.",
+		EnvVar: "AMQP_HEADER_MAPPING",
+	})
+	webhookEndpoints := app.String(cli.StringOpt{
+		Name:   "webhook_endpoints",
+		Value:  "",
+		Desc:   "JSON list of the webhook endpoints, e.g. `[{\"url\":\"https://example.com/hook\",\"secretEnv\":\"HOOK_SECRET\",\"maxAttempts\":5,\"backoff\":\"1s\",\"maxBackoff\":\"30s\"}]`. Defaults to producer_address signed with webhook_secret.",
+		EnvVar: "WEBHOOK_ENDPOINTS",
+	})
+	webhookSecret := app.String(cli.StringOpt{
+		Name:   "webhook_secret",
+		Value:  "",
+		Desc:   "HMAC secret of the producer_address webhook, when no webhook endpoints are listed.",
+		EnvVar: "WEBHOOK_SECRET",
+	})
+	fileRotateBytes := app.Int(cli.IntOpt{
+		Name:   "file_rotate_bytes",
+		Value:  104857600,
+		Desc:   "Size after which the file producer starts a new file, uncompressed. 0 disables the size based rotation.",
+		EnvVar: "FILE_ROTATE_BYTES",
+	})
+	fileRotateInterval := app.String(cli.StringOpt{
+		Name:   "file_rotate_interval",
+		Value:  "1h",
+		Desc:   "Time after which the file producer starts a new file. 0 disables the time based rotation.",
+		EnvVar: "FILE_ROTATE_INTERVAL",
+	})
+	fileGzip := app.Bool(cli.BoolOpt{
+		Name:   "file_gzip",
+		Value:  false,
+		Desc:   "Gzip the files written by the file producer.",
+		EnvVar: "FILE_GZIP",
+	})
+	kafkaProducerAcks := app.String(cli.StringOpt{
+		Name:   "kafka_producer_acks",
+		Value:  kafkaAcksAll,
+		Desc:   "Acknowledgements the kafka producer waits for: `all`, `leader` or `none`.",
+		EnvVar: "KAFKA_PRODUCER_ACKS",
+	})
+	kafkaProducerIdempotent := app.Bool(cli.BoolOpt{
+		Name:   "kafka_producer_idempotent",
+		Value:  true,
+		Desc:   "Enable the idempotent kafka producer, so that retries don't duplicate messages. Requires `all` acks.",
+		EnvVar: "KAFKA_PRODUCER_IDEMPOTENT",
+	})
+	kafkaVersion := app.String(cli.StringOpt{
+		Name:   "kafka_version",
+		Value:  defaultKafkaVersion,
+		Desc:   "Kafka protocol version used with the brokers.",
+		EnvVar: "KAFKA_VERSION",
+	})
+	kafkaTLSEnabled := app.Bool(cli.BoolOpt{
+		Name:   "kafka_tls_enabled",
+		Value:  false,
+		Desc:   "Connect to the kafka brokers over TLS.",
+		EnvVar: "KAFKA_TLS_ENABLED",
+	})
+	kafkaTLSCAFile := app.String(cli.StringOpt{
+		Name:   "kafka_tls_ca_file",
+		Value:  "",
+		Desc:   "PEM file of the CA certificates the kafka brokers are verified with. The system ones are used when empty.",
+		EnvVar: "KAFKA_TLS_CA_FILE",
+	})
+	kafkaTLSCertFile := app.String(cli.StringOpt{
+		Name:   "kafka_tls_cert_file",
+		Value:  "",
+		Desc:   "PEM file of the client certificate, for mutual TLS.",
+		EnvVar: "KAFKA_TLS_CERT_FILE",
+	})
+	kafkaTLSKeyFile := app.String(cli.StringOpt{
+		Name:   "kafka_tls_key_file",
+		Value:  "",
+		Desc:   "PEM file of the client key, for mutual TLS.",
+		EnvVar: "KAFKA_TLS_KEY_FILE",
+	})
+	kafkaTLSInsecureSkipVerify := app.Bool(cli.BoolOpt{
+		Name:   "kafka_tls_insecure_skip_verify",
+		Value:  false,
+		Desc:   "Don't verify the certificates of the kafka brokers.",
+		EnvVar: "KAFKA_TLS_INSECURE_SKIP_VERIFY",
+	})
+	kafkaSASLMechanism := app.String(cli.StringOpt{
+		Name:   "kafka_sasl_mechanism",
+		Value:  "",
+		Desc:   "SASL mechanism used with the kafka brokers: `PLAIN`, `SCRAM-SHA-256` or `SCRAM-SHA-512`. SASL is disabled when empty.",
+		EnvVar: "KAFKA_SASL_MECHANISM",
+	})
+	kafkaSASLUsername := app.String(cli.StringOpt{
+		Name:   "kafka_sasl_username",
+		Value:  "",
+		Desc:   "SASL username.",
+		EnvVar: "KAFKA_SASL_USERNAME",
+	})
+	kafkaSASLPassword := app.String(cli.StringOpt{
+		Name:   "kafka_sasl_password",
+		Value:  "",
+		Desc:   "SASL password.",
+		EnvVar: "KAFKA_SASL_PASSWORD",
+	})
+	plainHTTPHeaderMapping := app.String(cli.StringOpt{
+		Name:   "plain_http_header_mapping",
+		Value:  "",
+		Desc:   "JSON mapping of message headers to plainHTTP request headers with passThroughAll, exclude, keep, copy, rename, drop and static rules. The historical cms-notifier mapping is used when empty.",
+		EnvVar: "PLAIN_HTTP_HEADER_MAPPING",
+	})
+	plainHTTPMethod := app.String(cli.StringOpt{
+		Name:   "plain_http_method",
+		Value:  defaultPlainHTTPMethod,
+		Desc:   "The HTTP method used by the plainHTTP producer.",
+		EnvVar: "PLAIN_HTTP_METHOD",
+	})
+	plainHTTPPath := app.String(cli.StringOpt{
+		Name:   "plain_http_path",
+		Value:  defaultPlainHTTPPath,
+		Desc:   "The path the plainHTTP producer sends messages to. Placeholders are resolved from the message, e.g. `/content/{uuid}`, `/{header:Message-Type}` or `/{body:payload.uuid}`.",
+		EnvVar: "PLAIN_HTTP_PATH",
+	})
+	plainHTTPHealthPath := app.String(cli.StringOpt{
+		Name:   "plain_http_health_path",
+		Value:  defaultPlainHTTPHealthPath,
+		Desc:   "The path probed by the plainHTTP producer connectivity check.",
+		EnvVar: "PLAIN_HTTP_HEALTH_PATH",
+	})
+	plainHTTPCompression := app.String(cli.StringOpt{
+		Name:   "plain_http_compression",
+		Value:  "",
+		Desc:   "Compress plainHTTP request bodies with gzip or zstd. Disabled when empty.",
+		EnvVar: "PLAIN_HTTP_COMPRESSION",
+	})
+	plainHTTPCompressionThreshold := app.Int(cli.IntOpt{
+		Name:   "plain_http_compression_threshold",
+		Value:  1024,
+		Desc:   "Size in bytes below which plainHTTP request bodies are sent uncompressed.",
+		EnvVar: "PLAIN_HTTP_COMPRESSION_THRESHOLD",
+	})
+	plainHTTPCompressionProbe := app.Bool(cli.BoolOpt{
+		Name:   "plain_http_compression_probe",
+		Value:  true,
+		Desc:   "Only compress when the destination advertises the encoding in the Accept-Encoding header of an OPTIONS response.",
+		EnvVar: "PLAIN_HTTP_COMPRESSION_PROBE",
+	})
+	shadowProducerAddress := app.String(cli.StringOpt{
+		Name:   "shadow_producer_address",
+		Value:  "",
+		Desc:   "Optional address of a shadow destination which receives a copy of every message. The shadow never affects commits or health.",
+		EnvVar: "SHADOW_PRODUCER_ADDRESS",
+	})
+	shadowProducerAuth := app.String(cli.StringOpt{
+		Name:   "shadow_producer_auth",
+		Value:  "",
+		Desc:   "Shadow producer authentication string.",
+		EnvVar: "SHADOW_PRODUCER_AUTH",
+	})
+	shadowProducerType := app.String(cli.StringOpt{
+		Name:   "shadow_producer_type",
+		Value:  proxy,
+		Desc:   "The type of the shadow producer. Accepts the same values as producer_type.",
+		EnvVar: "SHADOW_PRODUCER_TYPE",
+	})
+	shadowLatencyTolerance := app.String(cli.StringOpt{
+		Name:   "shadow_latency_tolerance",
+		Value:  "1s",
+		Desc:   "Latency difference between the primary and the shadow destination above which a message is reported as a mismatch.",
+		EnvVar: "SHADOW_LATENCY_TOLERANCE",
+	})
+	shadowTimeout := app.String(cli.StringOpt{
+		Name:   "shadow_timeout",
+		Value:  "10s",
+		Desc:   "Time after which a shadow send is reported as failed.",
+		EnvVar: "SHADOW_TIMEOUT",
+	})
+	shadowMaxInFlight := app.Int(cli.IntOpt{
+		Name:   "shadow_max_in_flight",
+		Value:  100,
+		Desc:   "Maximum number of concurrent shadow sends. The messages arriving while they all run aren't sent to the shadow destination.",
+		EnvVar: "SHADOW_MAX_IN_FLIGHT",
+	})
+	batchMaxMessages := app.Int(cli.IntOpt{
+		Name:   "batch_max_messages",
+		Value:  0,
+		Desc:   "Maximum number of messages published in a single request. 0 disables batching, which is supported by the confluentRest, kafka, s3, nats and redis producers with the proxy source.",
+		EnvVar: "BATCH_MAX_MESSAGES",
+	})
+	batchMaxBytes := app.Int(cli.IntOpt{
+		Name:   "batch_max_bytes",
+		Value:  1048576,
+		Desc:   "Maximum total size of the message bodies published in a single request.",
+		EnvVar: "BATCH_MAX_BYTES",
+	})
+	batchLinger := app.String(cli.StringOpt{
+		Name:   "batch_linger",
+		Value:  "50ms",
+		Desc:   "Maximum time a message waits for its batch to fill up before the batch is published.",
+		EnvVar: "BATCH_LINGER",
+	})
+	maintenanceWindows := app.String(cli.StringOpt{
+		Name:   "maintenance_windows",
+		Value:  "",
+		Desc:   "Semicolon separated maintenance windows during which consuming is paused, e.g. `2026-10-20T01:00:00Z/2026-10-20T03:00:00Z;cron:0 2 * * SUN/2h`.",
+		EnvVar: "MAINTENANCE_WINDOWS",
+	})
+	priorityClasses := app.String(cli.StringOpt{
+		Name:   "priority_classes",
+		Value:  "",
+		Desc:   "JSON array of priority classes, highest priority first, e.g. `[{\"name\":\"takedowns\",\"header\":\"Message-Type\",\"values\":[\"cms-content-takedown\"]}]`.",
+		EnvVar: "PRIORITY_CLASSES",
+	})
+	bodyTransforms := app.String(cli.StringOpt{
+		Name:   "body_transforms",
+		Value:  "",
+		Desc:   "JSON array of transformations applied in order to every message body before forwarding, e.g. `[{\"type\":\"rename\",\"path\":\"value\",\"to\":\"payload.value\"}]`.",
+		EnvVar: "BODY_TRANSFORMS",
+	})
+	schemaDir := app.String(cli.StringOpt{
+		Name:   "schema_dir",
+		Value:  "",
+		Desc:   "Directory of JSON schemas laid out as <content type>/<X-Schema-Version>.json. Schema validation is disabled when empty.",
+		EnvVar: "SCHEMA_DIR",
+	})
+	deadLetterDir := app.String(cli.StringOpt{
+		Name:   "dead_letter_dir",
+		Value:  "",
+		Desc:   "Directory where messages which are not forwarded are stored as NDJSON. They are only logged when empty.",
+		EnvVar: "DEAD_LETTER_DIR",
+	})
+	provenanceEnabled := app.Bool(cli.BoolOpt{
+		Name:   "provenance_enabled",
+		Value:  false,
+		Desc:   "Add provenance headers (bridge service name, source, source topic, partition and offset when available, bridged-at timestamp) to forwarded messages.",
+		EnvVar: "PROVENANCE_ENABLED",
+	})
+	provenanceHeaderNames := app.String(cli.StringOpt{
+		Name:   "provenance_header_names",
+		Value:  "",
+		Desc:   "JSON object overriding the provenance header names, with service, source, topic, partition, offset and bridgedAt keys.",
+		EnvVar: "PROVENANCE_HEADER_NAMES",
+	})
+	headerCase := app.String(cli.StringOpt{
+		Name:   "header_case",
+		Value:  headerCaseCanonical,
+		Desc:   "How consumed header names are forwarded: `canonical` (x-request-id becomes X-Request-Id) or `preserve`. Header lookups are case-insensitive either way.",
+		EnvVar: "HEADER_CASE",
+	})
+	timestampPolicyName := app.String(cli.StringOpt{
+		Name:   "timestamp_policy",
+		Value:  timestampPreserve,
+		Desc:   "Message-Timestamp of the forwarded messages: `preserve` the consumed one or `rewrite` it with the forwarding time. It is generated when missing either way.",
+		EnvVar: "TIMESTAMP_POLICY",
+	})
+	secondaryTimestamps := app.Bool(cli.BoolOpt{
+		Name:   "secondary_timestamps",
+		Value:  false,
+		Desc:   "Add the X-Bridge-Received-At and X-Bridge-Forwarded-At headers to forwarded messages.",
+		EnvVar: "SECONDARY_TIMESTAMPS",
+	})
+	tidPolicy := app.String(cli.StringOpt{
+		Name:   "tid_policy",
+		Value:  tidPolicyAccept,
+		Desc:   "What happens to messages with an invalid X-Request-Id: `accept`, `regenerate` (the original is kept in the original tid header) or `reject` (dead-lettered).",
+		EnvVar: "TID_POLICY",
+	})
+	tidRegexp := app.String(cli.StringOpt{
+		Name:   "tid_valid_regexp",
+		Value:  tidValidRegexp,
+		Desc:   "Regular expression the whole X-Request-Id must match to be valid.",
+		EnvVar: "TID_VALID_REGEXP",
+	})
+	originalTIDHeader := app.String(cli.StringOpt{
+		Name:   "original_tid_header",
+		Value:  defaultOriginalTIDHeader,
+		Desc:   "Header keeping the original X-Request-Id of the messages whose transaction id was regenerated.",
+		EnvVar: "ORIGINAL_TID_HEADER",
+	})
+	outputFormat := app.String(cli.StringOpt{
+		Name:   "output_format",
+		Value:  ftOutputFormat,
+		Desc:   "Format of the forwarded messages: `ft`, `cloudevents-structured` or `cloudevents-binary`.",
+		EnvVar: "OUTPUT_FORMAT",
+	})
+	redactionRulesSpec := app.String(cli.StringOpt{
+		Name:   "redaction_rules",
+		Value:  "",
+		Desc:   "JSON object of body fields and headers redacted before forwarding, e.g. `{\"fields\":[{\"path\":\"byline\",\"action\":\"hash\"}],\"headers\":[\"Authorization\"]}`. Actions are blank, hash and drop.",
+		EnvVar: "REDACTION_RULES",
+	})
+	redactionVerify := app.Bool(cli.BoolOpt{
+		Name:   "redaction_verify",
+		Value:  false,
+		Desc:   "Log which fields and headers were redacted from each message.",
+		EnvVar: "REDACTION_VERIFY",
+	})
+	claimCheckMode := app.String(cli.StringOpt{
+		Name:   "claim_check_mode",
+		Value:  "",
+		Desc:   "Claim check mode: `store` replaces bodies over the threshold with a reference to the blob store, `rehydrate` replaces references with the stored bodies. Disabled when empty.",
+		EnvVar: "CLAIM_CHECK_MODE",
+	})
+	claimCheckThreshold := app.Int(cli.IntOpt{
+		Name:   "claim_check_threshold",
+		Value:  1024 * 1024,
+		Desc:   "Body size in bytes above which bodies are checked in to the blob store.",
+		EnvVar: "CLAIM_CHECK_THRESHOLD",
+	})
+	claimCheckStoreType := app.String(cli.StringOpt{
+		Name:   "claim_check_store",
+		Value:  fileBlobStoreType,
+		Desc:   "The claim check blob store: `file` or `s3`.",
+		EnvVar: "CLAIM_CHECK_STORE",
+	})
+	claimCheckDir := app.String(cli.StringOpt{
+		Name:   "claim_check_dir",
+		Value:  "",
+		Desc:   "Directory of the file claim check blob store.",
+		EnvVar: "CLAIM_CHECK_DIR",
+	})
+	claimCheckS3Bucket := app.String(cli.StringOpt{
+		Name:   "claim_check_s3_bucket",
+		Value:  "",
+		Desc:   "Bucket of the s3 claim check blob store.",
+		EnvVar: "CLAIM_CHECK_S3_BUCKET",
+	})
+	s3Endpoint := app.String(cli.StringOpt{
+		Name:   "s3_endpoint",
+		Value:  "s3.amazonaws.com",
+		Desc:   "Endpoint of the S3-compatible object storage.",
+		EnvVar: "S3_ENDPOINT",
+	})
+	s3Region := app.String(cli.StringOpt{
+		Name:   "s3_region",
+		Value:  "",
+		Desc:   "Region of the S3-compatible object storage.",
+		EnvVar: "S3_REGION",
+	})
+	s3AccessKeyID := app.String(cli.StringOpt{
+		Name:   "s3_access_key_id",
+		Value:  "",
+		Desc:   "Access key of the S3-compatible object storage. The AWS credential chain is used when empty.",
+		EnvVar: "S3_ACCESS_KEY_ID",
+	})
+	s3SecretAccessKey := app.String(cli.StringOpt{
+		Name:   "s3_secret_access_key",
+		Value:  "",
+		Desc:   "Secret key of the S3-compatible object storage.",
+		EnvVar: "S3_SECRET_ACCESS_KEY",
+	})
+	s3UseSSL := app.Bool(cli.BoolOpt{
+		Name:   "s3_use_ssl",
+		Value:  true,
+		Desc:   "Connect to the S3-compatible object storage over TLS.",
+		EnvVar: "S3_USE_SSL",
+	})
 	serviceName := app.String(cli.StringOpt{
 		Name:   "service_name",
 		Value:  appName,
@@ -155,11 +791,265 @@ func main() {
 	logger.Infof(nil, "Starting Kafka Bridge")
 
 	app.Action = func() {
-		bridgeApp := newBridgeApp(*consumerAddrs, *consumerGroup, *consumerOffset, *consumerAutoCommitEnable, *consumerAuthorizationKey, *topic, *producerAddress, *producerAuth, *producerType)
+		headerMapping, err := parseHeaderMapping(*plainHTTPHeaderMapping)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided plainHTTP header mapping is invalid")
+		}
+		var bridgeProvenance *provenance
+		if *provenanceEnabled {
+			names, err := parseProvenanceHeaderNames(*provenanceHeaderNames)
+			if err != nil {
+				logger.Fatalf(nil, err, "The provided provenance header names are invalid")
+			}
+			source := provenanceSource(*consumerAddrs, "")
+			switch *sourceType {
+			case kafka:
+				source = provenanceSource(*kafkaSourceBrokers, "")
+			case natsJetStream:
+				source = provenanceSource(*natsSourceServers, *natsSourceStream)
+			case redisStream:
+				source = provenanceSource(*redisSourceAddress, *redisSourceStream)
+			}
+			bridgeProvenance = &provenance{serviceName: *serviceName, source: source, topic: *topic, headers: names}
+			headerMapping = headerMapping.withKeep(names.all()...)
+		}
+		cloudEvents, err := newCloudEventsEncoder(*outputFormat, "/"+*serviceName)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided output format is invalid")
+		}
+		if *outputFormat == cloudEventsBinaryOutputFormat {
+			headerMapping = headerMapping.withKeep(cloudEventsBinaryHeaders...)
+		}
+		timestamps, err := newTimestampPolicy(*timestampPolicyName, *secondaryTimestamps)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided timestamp policy is invalid")
+		}
+		headerMapping = headerMapping.withKeep(timestamps.addedHeaders()...)
+		if *tidPolicy == tidPolicyRegenerate {
+			headerMapping = headerMapping.withKeep(*originalTIDHeader)
+		}
+		if *claimCheckMode == claimCheckStoreMode {
+			headerMapping = headerMapping.withKeep(claimCheckHeaders...)
+		}
+
+		path, err := parseURLTemplate(*plainHTTPPath)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided plainHTTP path is invalid")
+		}
+		compression, err := newRequestCompression(*plainHTTPCompression, *plainHTTPCompressionThreshold, *plainHTTPCompressionProbe)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided plainHTTP compression is invalid")
+		}
+		kafkaClient := kafkaClientConfig{
+			clientID:              *serviceName,
+			version:               *kafkaVersion,
+			tls:                   *kafkaTLSEnabled,
+			tlsCAFile:             *kafkaTLSCAFile,
+			tlsCertFile:           *kafkaTLSCertFile,
+			tlsKeyFile:            *kafkaTLSKeyFile,
+			tlsInsecureSkipVerify: *kafkaTLSInsecureSkipVerify,
+			saslMechanism:         *kafkaSASLMechanism,
+			saslUsername:          *kafkaSASLUsername,
+			saslPassword:          *kafkaSASLPassword,
+		}
+		rotateInterval, err := time.ParseDuration(*fileRotateInterval)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided file rotation interval is invalid")
+		}
+		natsClient := natsClientConfig{name: *serviceName, credsFile: *natsCredsFile}
+		s3 := s3Config{endpoint: *s3Endpoint, region: *s3Region, accessKeyID: *s3AccessKeyID, secretAccessKey: *s3SecretAccessKey, useSSL: *s3UseSSL}
+		producerOpts := producerOptions{
+			plainHTTP: plainHTTPConfig{
+				headerMapping: headerMapping,
+				method:        strings.ToUpper(*plainHTTPMethod),
+				path:          path,
+				healthPath:    *plainHTTPHealthPath,
+				compression:   compression,
+			},
+			rest: confluentRESTConfig{
+				apiVersion:     *restAPIVersion,
+				embeddedFormat: *restEmbeddedFormat,
+				clusterID:      *restClusterID,
+			},
+			file: fileSinkConfig{
+				rotateBytes:    int64(*fileRotateBytes),
+				rotateInterval: rotateInterval,
+				gzip:           *fileGzip,
+			},
+			s3: s3,
+			nats: natsProducerConfig{
+				client:  natsClient,
+				subject: *natsSubject,
+			},
+			redis: redisProducerConfig{
+				stream: *redisStreamName,
+				maxLen: int64(*redisMaxLen),
+			},
+			amqp: amqpProducerConfig{
+				exchange:      *amqpExchangeName,
+				routingKey:    *amqpRoutingKey,
+				headerMapping: *amqpHeaderMapping,
+			},
+			webhook: webhookConfig{
+				endpoints: *webhookEndpoints,
+				secret:    *webhookSecret,
+			},
+			kafka: kafkaProducerConfig{
+				client:     kafkaClient,
+				acks:       *kafkaProducerAcks,
+				idempotent: *kafkaProducerIdempotent,
+			},
+		}
+
+		bridgeApp := newBridgeApp(*consumerAddrs, *consumerGroup, *consumerOffset, *consumerAutoCommitEnable, *consumerAuthorizationKey, *topic, *producerAddress, *producerAuth, *producerType, producerOpts)
+		if *batchMaxMessages > 0 {
+			if *shadowProducerAddress != "" {
+				logger.Fatalf(nil, errors.New("batching can't be combined with a shadow producer"), "The provided batch settings are invalid")
+			}
+			if *sourceType != proxy {
+				// the other sources forward one message at a time, each one would wait for the linger time
+				logger.Fatalf(nil, fmt.Errorf("batching requires the %s source, the %s source forwards one message at a time", proxy, *sourceType), "The provided batch settings are invalid")
+			}
+			bridgeApp.enableBatching(*batchMaxMessages, *batchMaxBytes, *batchLinger)
+		}
+		if *shadowProducerAddress != "" {
+			bridgeApp.enableShadowProducer(*shadowProducerAddress, *shadowProducerAuth, *shadowProducerType, *shadowLatencyTolerance, *shadowTimeout, *shadowMaxInFlight)
+		}
+		maintenance, err := parseMaintenanceWindows(*maintenanceWindows)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided maintenance windows are invalid")
+		}
+		bridgeApp.maintenance = maintenance
+		lanes, err := parsePriorityClasses(*priorityClasses)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided priority classes are invalid")
+		}
+		bridgeApp.priorityLanes = lanes
+		transforms, err := parseBodyTransforms(*bodyTransforms)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided body transformations are invalid")
+		}
+		bridgeApp.bodyTransforms = transforms
+		bridgeApp.provenance = bridgeProvenance
+		bridgeApp.cloudEvents = cloudEvents
+		bridgeApp.timestamps = timestamps
+		switch *headerCase {
+		case headerCaseCanonical:
+		case headerCasePreserve:
+			bridgeApp.preserveHeaderCase = true
+		default:
+			logger.Fatalf(nil, fmt.Errorf("Unknown header case %s", *headerCase), "The provided header case is invalid")
+		}
+		bridgeApp.tidValidation, err = newTIDValidation(*tidPolicy, *tidRegexp, *originalTIDHeader)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided transaction id validation is invalid")
+		}
+		bridgeApp.redaction, err = parseRedactionRules(*redactionRulesSpec, *redactionVerify)
+		if err != nil {
+			logger.Fatalf(nil, err, "The provided redaction rules are invalid")
+		}
+		if *schemaDir != "" {
+			bridgeApp.schemaValidator, err = newSchemaValidator(*schemaDir)
+			if err != nil {
+				logger.Fatalf(nil, err, "The provided schema directory is invalid")
+			}
+		}
+		if *deadLetterDir != "" {
+			bridgeApp.deadLetters, err = newFileDeadLetterStore(*deadLetterDir)
+			if err != nil {
+				logger.Fatalf(nil, err, "Couldn't create the dead-letter store")
+			}
+		}
+		if *claimCheckMode != "" {
+			store, err := newBlobStore(*claimCheckStoreType, *claimCheckDir, *claimCheckS3Bucket, s3)
+			if err != nil {
+				logger.Fatalf(nil, err, "Couldn't create the claim check blob store")
+			}
+			bridgeApp.claimCheck, err = newClaimCheck(*claimCheckMode, *claimCheckThreshold, store, *topic)
+			if err != nil {
+				logger.Fatalf(nil, err, "The provided claim check mode is invalid")
+			}
+		}
+		switch *sourceType {
+		case proxy:
+		case kafka:
+			commitInterval, err := time.ParseDuration(*kafkaSourceCommitInterval)
+			if err != nil {
+				logger.Fatalf(nil, err, "The provided kafka source commit interval is invalid")
+			}
+			sourceConfig := kafkaSourceConfig{
+				client:            kafkaClient,
+				group:             *consumerGroup,
+				topic:             *topic,
+				offset:            *consumerOffset,
+				rebalanceStrategy: *kafkaSourceRebalanceStrategy,
+				commitInterval:    commitInterval,
+			}
+			// the bridge is complete at this point, forward must see every feature enabled above
+			bridgeApp.source, err = newKafkaGroupSource(*kafkaSourceBrokers, sourceConfig, func(msg consumer.Message, position *sourcePosition) {
+				bridgeApp.forward(msg, position)
+			})
+			if err != nil {
+				logger.Fatalf(nil, err, "Couldn't create the kafka source")
+			}
+		case natsJetStream:
+			sourceConfig := natsSourceConfig{
+				client:  natsClient,
+				durable: *consumerGroup,
+				stream:  *natsSourceStream,
+				subject: *natsSourceSubject,
+				offset:  *consumerOffset,
+			}
+			bridgeApp.source, err = newNATSStreamSource(*natsSourceServers, sourceConfig, func(msg consumer.Message, position *sourcePosition) {
+				bridgeApp.forward(msg, position)
+			})
+			if err != nil {
+				logger.Fatalf(nil, err, "Couldn't create the NATS source")
+			}
+		case redisStream:
+			sourceConfig := redisSourceConfig{
+				group:    *consumerGroup,
+				consumer: *redisSourceConsumer,
+				stream:   *redisSourceStream,
+				offset:   *consumerOffset,
+			}
+			bridgeApp.source, err = newRedisStreamSource(*redisSourceAddress, sourceConfig, func(msg consumer.Message, position *sourcePosition) {
+				bridgeApp.forward(msg, position)
+			})
+			if err != nil {
+				logger.Fatalf(nil, err, "Couldn't create the redis source")
+			}
+		default:
+			logger.Fatalf(nil, fmt.Errorf("Unknown source type %s", *sourceType), "The provided source type is invalid")
+		}
+		bridgeApp.sourceType = *sourceType
 		go bridgeApp.enableHealthchecksAndGTG()
 		bridgeApp.consumeMessages()
+		if closer, ok := bridgeApp.producerInstance.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Errorf(nil, err, "Couldn't close the producer")
+			}
+		}
+		if closer, ok := bridgeApp.source.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				logger.Errorf(nil, err, "Couldn't close the source")
+			}
+		}
 	}
 
+	app.Command("dry-run", "Preview the body transformations applied to a sample message body", func(cmd *cli.Cmd) {
+		sample := cmd.StringArg("SAMPLE", "-", "File containing the sample message body, - for stdin")
+		cmd.Action = func() {
+			transforms, err := parseBodyTransforms(*bodyTransforms)
+			if err != nil {
+				logger.Fatalf(nil, err, "The provided body transformations are invalid")
+			}
+			if err := previewBodyTransforms(transforms, *sample, os.Stdin, os.Stdout); err != nil {
+				logger.Fatalf(nil, err, "Dry-run failed")
+			}
+		}
+	})
+
 	err := app.Run(os.Args)
 	if err != nil {
 		logger.Errorf(nil, err, "App could not start")
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	"github.com/IBM/sarama"
+	"github.com/xdg-go/scram"
+)
+
+const (
+	saslPlain       = "PLAIN"
+	saslScramSHA256 = "SCRAM-SHA-256"
+	saslScramSHA512 = "SCRAM-SHA-512"
+
+	defaultKafkaVersion = "2.1.0"
+)
+
+// kafkaClientConfig holds the connection settings of the native Kafka clients
+type kafkaClientConfig struct {
+	brokers  []string
+	clientID string
+	version  string
+
+	tls                   bool
+	tlsCAFile             string
+	tlsCertFile           string
+	tlsKeyFile            string
+	tlsInsecureSkipVerify bool
+
+	saslMechanism string
+	saslUsername  string
+	saslPassword  string
+}
+
+// parseKafkaBrokers splits a comma separated list of host:port broker addresses
+func parseKafkaBrokers(addrs string) []string {
+	var brokers []string
+	for _, addr := range strings.Split(addrs, ",") {
+		addr = strings.TrimSpace(addr)
+		addr = strings.TrimPrefix(strings.TrimPrefix(addr, "kafka://"), "tcp://")
+		if addr != "" {
+			brokers = append(brokers, addr)
+		}
+	}
+	return brokers
+}
+
+// saramaConfig returns the client configuration, which the producer and the source complete with their own settings
+func (c kafkaClientConfig) saramaConfig() (*sarama.Config, error) {
+	if len(c.brokers) == 0 {
+		return nil, errors.New("at least one Kafka broker is required")
+	}
+
+	config := sarama.NewConfig()
+	if c.clientID != "" {
+		config.ClientID = c.clientID
+	}
+	versionName := c.version
+	if versionName == "" {
+		versionName = defaultKafkaVersion
+	}
+	version, err := sarama.ParseKafkaVersion(versionName)
+	if err != nil {
+		return nil, err
+	}
+	config.Version = version
+
+	if c.tls {
+		tlsConfig, err := c.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		config.Net.TLS.Enable = true
+		config.Net.TLS.Config = tlsConfig
+	}
+
+	switch c.saslMechanism {
+	case "":
+	case saslPlain:
+		config.Net.SASL.Mechanism = sarama.SASLTypePlaintext
+	case saslScramSHA256:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA256
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: scram.SHA256} }
+	case saslScramSHA512:
+		config.Net.SASL.Mechanism = sarama.SASLTypeSCRAMSHA512
+		config.Net.SASL.SCRAMClientGeneratorFunc = func() sarama.SCRAMClient { return &scramClient{hash: scram.SHA512} }
+	default:
+		return nil, errors.New("unsupported SASL mechanism " + c.saslMechanism)
+	}
+	if c.saslMechanism != "" {
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = c.saslUsername
+		config.Net.SASL.Password = c.saslPassword
+	}
+	return config, config.Validate()
+}
+
+func (c kafkaClientConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.tlsInsecureSkipVerify}
+	if c.tlsCAFile != "" {
+		ca, err := ioutil.ReadFile(c.tlsCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, errors.New("no certificate found in " + c.tlsCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if c.tlsCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.tlsCertFile, c.tlsKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// scramClient adapts the xdg-go SCRAM implementation to sarama
+type scramClient struct {
+	hash         scram.HashGeneratorFcn
+	conversation *scram.ClientConversation
+}
+
+func (c *scramClient) Begin(userName, password, authzID string) error {
+	client, err := c.hash.NewClient(userName, password, authzID)
+	if err != nil {
+		return err
+	}
+	c.conversation = client.NewConversation()
+	return nil
+}
+
+func (c *scramClient) Step(challenge string) (string, error) {
+	return c.conversation.Step(challenge)
+}
+
+func (c *scramClient) Done() bool {
+	return c.conversation.Done()
+}
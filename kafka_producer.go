@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/IBM/sarama"
+)
+
+const (
+	kafkaAcksAll    = "all"
+	kafkaAcksLeader = "leader"
+	kafkaAcksNone   = "none"
+
+	ftMessageVersion = "FTMSG/1.0"
+	crlf             = "\r\n"
+)
+
+// kafkaProducerConfig holds the settings specific to the kafka producer
+type kafkaProducerConfig struct {
+	client     kafkaClientConfig
+	acks       string
+	idempotent bool
+}
+
+// kafkaMessageProducer writes the messages straight to the destination brokers. The record value is
+// the FT message, as written by the kafka proxy, and the message headers are also set as record headers.
+// Records are keyed by content UUID, so that the messages about a piece of content keep their order.
+type kafkaMessageProducer struct {
+	topic    string
+	client   sarama.Client
+	producer sarama.SyncProducer
+}
+
+func newKafkaMessageProducer(brokers string, topic string, config kafkaProducerConfig) (queueProducer.MessageProducer, error) {
+	config.client.brokers = parseKafkaBrokers(brokers)
+	saramaConfig, err := config.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sarama.NewClient(config.client.brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	producer, err := sarama.NewSyncProducerFromClient(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &kafkaMessageProducer{topic: topic, client: client, producer: producer}, nil
+}
+
+func (c kafkaProducerConfig) saramaConfig() (*sarama.Config, error) {
+	config, err := c.client.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.acks {
+	case "", kafkaAcksAll:
+		config.Producer.RequiredAcks = sarama.WaitForAll
+	case kafkaAcksLeader:
+		config.Producer.RequiredAcks = sarama.WaitForLocal
+	case kafkaAcksNone:
+		config.Producer.RequiredAcks = sarama.NoResponse
+	default:
+		return nil, errors.New("unknown producer acks " + c.acks)
+	}
+	if c.idempotent {
+		if config.Producer.RequiredAcks != sarama.WaitForAll {
+			return nil, errors.New("the idempotent producer requires all acks")
+		}
+		config.Producer.Idempotent = true
+		config.Net.MaxOpenRequests = 1
+	}
+	config.Producer.Return.Successes = true
+	config.Producer.Return.Errors = true
+	return config, config.Validate()
+}
+
+func (p *kafkaMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	_, _, err := p.producer.SendMessage(newKafkaRecord(p.topic, uuid, message))
+	if err != nil {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+	}
+	return nil
+}
+
+// SendBatch produces the records in a single call, the failed records are matched back to their message
+func (p *kafkaMessageProducer) SendBatch(messages []queueProducer.Message) []error {
+	errs := make([]error, len(messages))
+	records := make([]*sarama.ProducerMessage, len(messages))
+	index := make(map[*sarama.ProducerMessage]int, len(messages))
+	for i, message := range messages {
+		records[i] = newKafkaRecord(p.topic, "", message)
+		index[records[i]] = i
+	}
+
+	err := p.producer.SendMessages(records)
+	if err == nil {
+		return errs
+	}
+	var producerErrs sarama.ProducerErrors
+	if !errors.As(err, &producerErrs) {
+		for i, message := range messages {
+			errs[i] = fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+		}
+		return errs
+	}
+	for _, producerErr := range producerErrs {
+		if i, found := index[producerErr.Msg]; found {
+			errs[i] = fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(messages[i].Headers).Get("X-Request-Id"), producerErr.Err)
+		}
+	}
+	return errs
+}
+
+// ConnectivityCheck requests the metadata of the destination topic, which fails when no broker is reachable
+func (p *kafkaMessageProducer) ConnectivityCheck() (string, error) {
+	if err := p.client.RefreshMetadata(p.topic); err != nil {
+		return "Forwarding messages is broken. Couldn't get the metadata of topic " + p.topic, err
+	}
+	partitions, err := p.client.WritablePartitions(p.topic)
+	if err != nil {
+		return "Forwarding messages is broken. Couldn't get the partitions of topic " + p.topic, err
+	}
+	if len(partitions) == 0 {
+		return "Forwarding messages is broken. No writable partition for topic " + p.topic, errors.New("no writable partition")
+	}
+	return "Connectivity to the destination brokers is OK", nil
+}
+
+// Close flushes and closes the producer, then closes the client it was created from, which it doesn't own
+func (p *kafkaMessageProducer) Close() error {
+	producerErr := p.producer.Close()
+	return errors.Join(producerErr, p.client.Close())
+}
+
+func newKafkaRecord(topic string, uuid string, message queueProducer.Message) *sarama.ProducerMessage {
+	record := &sarama.ProducerMessage{
+		Topic: topic,
+		Value: sarama.StringEncoder(buildFTMessage(message)),
+	}
+	if key := recordKey(uuid, message); key != "" {
+		record.Key = sarama.StringEncoder(key)
+	}
+	for _, name := range sortedHeaderNames(message.Headers) {
+		record.Headers = append(record.Headers, sarama.RecordHeader{Key: []byte(name), Value: []byte(message.Headers[name])})
+	}
+	return record
+}
+
+// recordKey returns the content UUID the record is partitioned by: the one given to the producer,
+// the uuid field of JSON bodies, or else the Message-Id
+func recordKey(uuid string, message queueProducer.Message) string {
+	if uuid != "" {
+		return uuid
+	}
+	if uuid := contentUUID(message.Body); uuid != "" {
+		return uuid
+	}
+	return messageHeaders(message.Headers).Get("Message-Id")
+}
+
+// contentUUID returns the uuid field of JSON bodies, or an empty string
+func contentUUID(body string) string {
+	if !strings.HasPrefix(strings.TrimSpace(body), "{") {
+		return ""
+	}
+	doc, err := decodeJSON([]byte(body))
+	if err != nil {
+		return ""
+	}
+	uuid, _ := jsonFieldString(doc, "uuid")
+	return uuid
+}
+
+// buildFTMessage formats the message the way the kafka proxy producer does
+func buildFTMessage(message queueProducer.Message) string {
+	var b strings.Builder
+	b.WriteString(ftMessageVersion + crlf)
+	for _, name := range sortedHeaderNames(message.Headers) {
+		b.WriteString(name + ": " + message.Headers[name] + crlf)
+	}
+	b.WriteString(crlf + message.Body)
+	return b.String()
+}
+
+func sortedHeaderNames(headers map[string]string) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/IBM/sarama"
+	"github.com/IBM/sarama/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildFTMessage(t *testing.T) {
+	msg := queueProducer.Message{
+		Headers: map[string]string{
+			"X-Request-Id":      "SYNTHETIC-REQ-MON_A391MMaVMv",
+			"Message-Type":      "cms-content-published",
+			"Content-Type":      "application/json",
+			"Message-Timestamp": "2015-10-21T14:22:06.270Z",
+		},
+		Body: `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`,
+	}
+	expected := "FTMSG/1.0\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Message-Timestamp: 2015-10-21T14:22:06.270Z\r\n" +
+		"Message-Type: cms-content-published\r\n" +
+		"X-Request-Id: SYNTHETIC-REQ-MON_A391MMaVMv\r\n" +
+		"\r\n" +
+		`{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`
+	assert.Equal(t, expected, buildFTMessage(msg))
+}
+
+func TestRecordKey(t *testing.T) {
+	headers := map[string]string{"Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c"}
+	var tests = []struct {
+		name     string
+		uuid     string
+		body     string
+		expected string
+	}{
+		{"given uuid", "7543220a-2389-11e5-bd83-71cb60e8f08c", `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`, "7543220a-2389-11e5-bd83-71cb60e8f08c"},
+		{"body uuid", "", `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`, "c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"},
+		{"no body uuid", "", `{"id":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`, "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+		{"not JSON", "", `<content/>`, "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, recordKey(test.uuid, queueProducer.Message{Headers: headers, Body: test.body}), test.name)
+	}
+}
+
+func TestKafkaSendMessage(t *testing.T) {
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	mock := mocks.NewSyncProducer(t, config)
+	mock.ExpectSendMessageWithMessageCheckerFunctionAndSucceed(func(record *sarama.ProducerMessage) error {
+		assert.Equal(t, "NativeCmsPublicationEvents", record.Topic)
+		key, _ := record.Key.Encode()
+		assert.Equal(t, "c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3", string(key))
+		assert.Equal(t, []sarama.RecordHeader{
+			{Key: []byte("Content-Type"), Value: []byte("application/json")},
+			{Key: []byte("X-Request-Id"), Value: []byte("tid_test")},
+		}, record.Headers)
+		return nil
+	})
+	mock.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+
+	p := &kafkaMessageProducer{topic: "NativeCmsPublicationEvents", producer: mock}
+	msg := queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"},
+		Body:    `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`,
+	}
+	assert.NoError(t, p.SendMessage("", msg))
+	err := p.SendMessage("", msg)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "tid_test")
+	assert.NoError(t, mock.Close())
+}
+
+// partialFailureProducer fails the records whose value ends with "fail", like the brokers rejecting part of a batch
+type partialFailureProducer struct {
+	sarama.SyncProducer
+}
+
+func (p partialFailureProducer) SendMessages(records []*sarama.ProducerMessage) error {
+	var errs sarama.ProducerErrors
+	for _, record := range records {
+		if value, _ := record.Value.Encode(); strings.HasSuffix(string(value), "fail") {
+			errs = append(errs, &sarama.ProducerError{Msg: record, Err: sarama.ErrMessageSizeTooLarge})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func TestKafkaSendBatch(t *testing.T) {
+	messages := []queueProducer.Message{
+		{Headers: map[string]string{"X-Request-Id": "tid_first"}, Body: `{}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_second"}, Body: `fail`},
+	}
+
+	p := &kafkaMessageProducer{topic: "NativeCmsPublicationEvents", producer: partialFailureProducer{}}
+	errs := p.SendBatch(messages)
+	assert.NoError(t, errs[0])
+	assert.Error(t, errs[1])
+	assert.Contains(t, errs[1].Error(), "tid_second")
+
+	config := sarama.NewConfig()
+	config.Producer.Return.Successes = true
+	mock := mocks.NewSyncProducer(t, config)
+	mock.ExpectSendMessageAndFail(sarama.ErrNotLeaderForPartition)
+	mock.ExpectSendMessageAndSucceed()
+	p = &kafkaMessageProducer{topic: "NativeCmsPublicationEvents", producer: mock}
+	for _, err := range p.SendBatch(messages) {
+		assert.Error(t, err, "Every message fails when the failed records are unknown")
+	}
+	assert.NoError(t, mock.Close())
+}
+
+// closeRecorder records the order in which the producer and its client are closed
+type closeRecorder struct {
+	closed []string
+}
+
+type recordingCloseProducer struct {
+	sarama.SyncProducer
+	recorder *closeRecorder
+}
+
+func (p recordingCloseProducer) Close() error {
+	p.recorder.closed = append(p.recorder.closed, "producer")
+	return nil
+}
+
+type recordingCloseClient struct {
+	sarama.Client
+	recorder *closeRecorder
+}
+
+func (c recordingCloseClient) Close() error {
+	c.recorder.closed = append(c.recorder.closed, "client")
+	return sarama.ErrClosedClient
+}
+
+func TestKafkaProducerClose(t *testing.T) {
+	recorder := &closeRecorder{}
+	p := &kafkaMessageProducer{topic: "NativeCmsPublicationEvents", producer: recordingCloseProducer{recorder: recorder}, client: recordingCloseClient{recorder: recorder}}
+	assert.ErrorIs(t, p.Close(), sarama.ErrClosedClient, "The client error is returned")
+	assert.Equal(t, []string{"producer", "client"}, recorder.closed)
+}
+
+func TestKafkaProducerConfig(t *testing.T) {
+	client := kafkaClientConfig{brokers: []string{"localhost:9092"}}
+
+	config, err := kafkaProducerConfig{client: client, acks: kafkaAcksAll, idempotent: true}.saramaConfig()
+	assert.NoError(t, err)
+	assert.True(t, config.Producer.Idempotent)
+	assert.Equal(t, sarama.WaitForAll, config.Producer.RequiredAcks)
+
+	_, err = kafkaProducerConfig{client: client, acks: kafkaAcksLeader, idempotent: true}.saramaConfig()
+	assert.Error(t, err, "The idempotent producer requires all acks")
+	_, err = kafkaProducerConfig{client: client, acks: "some"}.saramaConfig()
+	assert.Error(t, err)
+
+	scramClient := client
+	scramClient.saslMechanism, scramClient.saslUsername, scramClient.saslPassword = saslScramSHA512, "bridge", "secret"
+	config, err = kafkaProducerConfig{client: scramClient}.saramaConfig()
+	assert.NoError(t, err)
+	assert.True(t, config.Net.SASL.Enable)
+	assert.Equal(t, sarama.SASLMechanism(sarama.SASLTypeSCRAMSHA512), config.Net.SASL.Mechanism)
+
+	_, err = kafkaProducerConfig{client: kafkaClientConfig{}}.saramaConfig()
+	assert.Error(t, err, "Brokers are required")
+}
+
+func TestParseKafkaBrokers(t *testing.T) {
+	assert.Equal(t, []string{"kafka-1:9092", "kafka-2:9092"}, parseKafkaBrokers("kafka://kafka-1:9092, kafka-2:9092,"))
+	assert.Nil(t, parseKafkaBrokers(""))
+}
+
+// TestKafkaProducerIntegration runs against a local broker, e.g. a single-node docker kafka,
+// when KAFKA_TEST_BROKERS is set. The topic must exist or be auto-created.
+func TestKafkaProducerIntegration(t *testing.T) {
+	brokers := os.Getenv("KAFKA_TEST_BROKERS")
+	if brokers == "" {
+		t.Skip("KAFKA_TEST_BROKERS is not set")
+	}
+	topic := "kafka-bridge-test-" + strings.ToLower(time.Now().UTC().Format("20060102T150405"))
+
+	p, err := newKafkaMessageProducer(brokers, topic, kafkaProducerConfig{acks: kafkaAcksAll, idempotent: true})
+	assert.NoError(t, err)
+	assert.NoError(t, p.SendMessage("", queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_integration", "Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+		Body:    `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`,
+	}))
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+}
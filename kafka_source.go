@@ -0,0 +1,302 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/IBM/sarama"
+)
+
+const (
+	partitionsPath = "/__partitions"
+
+	kafkaSourceRetryInterval = 5 * time.Second
+)
+
+// kafkaSourceConfig holds the settings of the native kafka source
+type kafkaSourceConfig struct {
+	client            kafkaClientConfig
+	group             string
+	topic             string
+	offset            string
+	rebalanceStrategy string
+	commitInterval    time.Duration
+}
+
+// partitionProgress is what the /__partitions endpoint reports for each assigned partition
+type partitionProgress struct {
+	Partition     int32     `json:"partition"`
+	Offset        int64     `json:"offset"`
+	HighWaterMark int64     `json:"highWaterMark"`
+	Lag           int64     `json:"lag"`
+	AssignedAt    time.Time `json:"assignedAt"`
+}
+
+// kafkaGroupSource joins a consumer group straight against the brokers instead of going through the kafka proxy.
+// Offsets are committed explicitly, after the messages have been forwarded, so that a crash replays them
+// rather than losing them. The partitions currently assigned to this member are exposed at /__partitions.
+// Each membership of the group has its own connections, closed when it stops, while client serves the health
+// checks until the source is closed.
+type kafkaGroupSource struct {
+	client         sarama.Client
+	brokers        []string
+	config         *sarama.Config
+	group          string
+	topic          string
+	commitInterval time.Duration
+	forward        func(msg queueConsumer.Message, position *sourcePosition)
+
+	sync.Mutex
+	memberID   string
+	generation int32
+	partitions map[int32]*partitionProgress
+}
+
+func newKafkaGroupSource(brokers string, config kafkaSourceConfig, forward func(queueConsumer.Message, *sourcePosition)) (*kafkaGroupSource, error) {
+	if config.group == "" || config.topic == "" {
+		return nil, errors.New("the kafka source requires a group id and a topic")
+	}
+	config.client.brokers = parseKafkaBrokers(brokers)
+	saramaConfig, err := config.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := sarama.NewClient(config.client.brokers, saramaConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaGroupSource{
+		client:         client,
+		brokers:        config.client.brokers,
+		config:         saramaConfig,
+		group:          config.group,
+		topic:          config.topic,
+		commitInterval: config.commitInterval,
+		forward:        forward,
+		partitions:     make(map[int32]*partitionProgress),
+	}, nil
+}
+
+func (c kafkaSourceConfig) saramaConfig() (*sarama.Config, error) {
+	config, err := c.client.saramaConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.offset {
+	case "", "largest", "latest", "newest":
+		config.Consumer.Offsets.Initial = sarama.OffsetNewest
+	case "smallest", "earliest", "oldest":
+		config.Consumer.Offsets.Initial = sarama.OffsetOldest
+	default:
+		return nil, errors.New("unknown consumer offset " + c.offset)
+	}
+	switch c.rebalanceStrategy {
+	case "", "range":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRange()}
+	case "roundrobin":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategyRoundRobin()}
+	case "sticky":
+		config.Consumer.Group.Rebalance.GroupStrategies = []sarama.BalanceStrategy{sarama.NewBalanceStrategySticky()}
+	default:
+		return nil, errors.New("unknown rebalance strategy " + c.rebalanceStrategy)
+	}
+	config.Consumer.Offsets.AutoCommit.Enable = false
+	config.Consumer.Return.Errors = true
+	return config, config.Validate()
+}
+
+// consumer returns a group member which consumes until it is stopped. A new one is needed after every stop,
+// e.g. when resuming after a maintenance window.
+func (s *kafkaGroupSource) consumer() queueConsumer.MessageConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &kafkaGroupConsumer{source: s, ctx: ctx, cancel: cancel}
+}
+
+// ConnectivityCheck requests the metadata of the source topic, which fails when no broker is reachable
+func (s *kafkaGroupSource) ConnectivityCheck() (string, error) {
+	if err := s.client.RefreshMetadata(s.topic); err != nil {
+		return "Consuming messages is broken. Couldn't get the metadata of topic " + s.topic, err
+	}
+	if _, err := s.client.Partitions(s.topic); err != nil {
+		return "Consuming messages is broken. Couldn't get the partitions of topic " + s.topic, err
+	}
+	return "Connectivity to the source brokers is OK", nil
+}
+
+// Close closes the connections the health checks use, once the last consumer has stopped
+func (s *kafkaGroupSource) Close() error {
+	return s.client.Close()
+}
+
+// consume joins the group with a client of its own, which closing the group closes
+func (s *kafkaGroupSource) consume(ctx context.Context) {
+	group, err := sarama.NewConsumerGroup(s.brokers, s.group, s.config)
+	if err != nil {
+		logger.Errorf(nil, err, "Couldn't create the consumer group "+s.group)
+		return
+	}
+	defer group.Close()
+
+	go func() {
+		for err := range group.Errors() {
+			logger.Errorf(map[string]interface{}{"group": s.group}, err, "Consumer group error")
+		}
+	}()
+
+	for ctx.Err() == nil {
+		// Consume returns at every rebalance, when it is called again to join the new generation
+		if err := group.Consume(ctx, []string{s.topic}, s); err != nil {
+			if errors.Is(err, sarama.ErrClosedConsumerGroup) {
+				return
+			}
+			logger.Errorf(map[string]interface{}{"group": s.group}, err, "Consuming from the kafka brokers failed, retrying")
+			select {
+			case <-ctx.Done():
+			case <-time.After(kafkaSourceRetryInterval):
+			}
+		}
+	}
+}
+
+// Setup is called when partitions are assigned to this member, after a rebalance
+func (s *kafkaGroupSource) Setup(session sarama.ConsumerGroupSession) error {
+	s.Lock()
+	defer s.Unlock()
+	s.memberID = session.MemberID()
+	s.generation = session.GenerationID()
+	s.partitions = make(map[int32]*partitionProgress)
+	now := time.Now()
+	for _, partition := range session.Claims()[s.topic] {
+		s.partitions[partition] = &partitionProgress{Partition: partition, Offset: -1, HighWaterMark: -1, AssignedAt: now}
+	}
+	logger.Infof(map[string]interface{}{"group": s.group, "member": s.memberID, "generation": s.generation, "partitions": formatPartitions(session.Claims()[s.topic])}, "Kafka partitions assigned")
+	return nil
+}
+
+// Cleanup is called when the partitions are revoked, before the next rebalance or when stopping.
+// The offsets marked so far are committed so that the next owner of the partitions carries on from there.
+func (s *kafkaGroupSource) Cleanup(session sarama.ConsumerGroupSession) error {
+	session.Commit()
+	s.Lock()
+	defer s.Unlock()
+	logger.Infof(map[string]interface{}{"group": s.group, "member": s.memberID, "generation": s.generation, "partitions": formatPartitions(session.Claims()[s.topic])}, "Kafka partitions revoked")
+	s.partitions = make(map[int32]*partitionProgress)
+	return nil
+}
+
+// ConsumeClaim forwards the messages of a partition in order, marking each one once forwarded. The marked
+// offsets are committed every commit interval, or after every message without one.
+func (s *kafkaGroupSource) ConsumeClaim(session sarama.ConsumerGroupSession, claim sarama.ConsumerGroupClaim) error {
+	var commitTicks <-chan time.Time
+	if s.commitInterval > 0 {
+		ticker := time.NewTicker(s.commitInterval)
+		defer ticker.Stop()
+		commitTicks = ticker.C
+	}
+	for {
+		select {
+		case record, ok := <-claim.Messages():
+			if !ok {
+				return nil
+			}
+			s.forward(parseFTMessage(record.Value, record.Headers), &sourcePosition{partitioned: true, partition: record.Partition, offset: record.Offset})
+			session.MarkMessage(record, "")
+			s.progress(record.Partition, record.Offset, claim.HighWaterMarkOffset())
+			if commitTicks == nil {
+				session.Commit()
+			}
+		case <-commitTicks:
+			session.Commit()
+		case <-session.Context().Done():
+			return nil
+		}
+	}
+}
+
+func (s *kafkaGroupSource) progress(partition int32, offset int64, highWaterMark int64) {
+	s.Lock()
+	defer s.Unlock()
+	if p, found := s.partitions[partition]; found {
+		p.Offset = offset
+		p.HighWaterMark = highWaterMark
+		p.Lag = highWaterMark - offset - 1
+	}
+}
+
+// partitionsHandler reports the partitions assigned to this member and how far they have been forwarded
+func (s *kafkaGroupSource) partitionsHandler(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	report := struct {
+		Group      string              `json:"group"`
+		Topic      string              `json:"topic"`
+		MemberID   string              `json:"memberId"`
+		Generation int32               `json:"generation"`
+		Partitions []partitionProgress `json:"partitions"`
+	}{Group: s.group, Topic: s.topic, MemberID: s.memberID, Generation: s.generation, Partitions: []partitionProgress{}}
+	for _, p := range s.partitions {
+		report.Partitions = append(report.Partitions, *p)
+	}
+	s.Unlock()
+
+	sort.Slice(report.Partitions, func(i, j int) bool { return report.Partitions[i].Partition < report.Partitions[j].Partition })
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// kafkaGroupConsumer is a single membership of the consumer group, from Start until Stop
+type kafkaGroupConsumer struct {
+	source *kafkaGroupSource
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (c *kafkaGroupConsumer) Start() {
+	c.source.consume(c.ctx)
+}
+
+func (c *kafkaGroupConsumer) Stop() {
+	c.cancel()
+}
+
+func (c *kafkaGroupConsumer) ConnectivityCheck() (string, error) {
+	return c.source.ConnectivityCheck()
+}
+
+// parseFTMessage reads a record written by the kafka proxy or the kafka producer. Records which are not
+// FT messages are forwarded as they are, with their record headers.
+func parseFTMessage(value []byte, recordHeaders []*sarama.RecordHeader) queueConsumer.Message {
+	msg := queueConsumer.Message{Headers: make(map[string]string), Body: string(value)}
+	if rest := string(value); strings.HasPrefix(rest, ftMessageVersion+crlf) {
+		rest = rest[len(ftMessageVersion+crlf):]
+		if end := strings.Index(crlf+rest, crlf+crlf); end >= 0 {
+			for _, line := range strings.Split(rest[:end], crlf) {
+				if parts := strings.SplitN(line, ":", 2); len(parts) == 2 {
+					msg.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+				}
+			}
+			msg.Body = rest[end+len(crlf):]
+		}
+	}
+	for _, header := range recordHeaders {
+		if _, found := msg.Headers[string(header.Key)]; !found {
+			msg.Headers[string(header.Key)] = string(header.Value)
+		}
+	}
+	return msg
+}
+
+func formatPartitions(partitions []int32) string {
+	sorted := append([]int32{}, partitions...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	encoded, _ := json.Marshal(sorted)
+	return string(encoded)
+}
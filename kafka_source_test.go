@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/IBM/sarama"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeGroupSession struct {
+	ctx     context.Context
+	claims  map[string][]int32
+	marked  []int64
+	commits int
+}
+
+func (s *fakeGroupSession) Claims() map[string][]int32 { return s.claims }
+func (s *fakeGroupSession) MemberID() string           { return "member-1" }
+func (s *fakeGroupSession) GenerationID() int32        { return 3 }
+func (s *fakeGroupSession) MarkOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (s *fakeGroupSession) Commit() { s.commits++ }
+func (s *fakeGroupSession) ResetOffset(topic string, partition int32, offset int64, metadata string) {
+}
+func (s *fakeGroupSession) MarkMessage(msg *sarama.ConsumerMessage, metadata string) {
+	s.marked = append(s.marked, msg.Offset)
+}
+func (s *fakeGroupSession) Context() context.Context { return s.ctx }
+
+type fakeGroupClaim struct {
+	partition int32
+	messages  chan *sarama.ConsumerMessage
+}
+
+func (c *fakeGroupClaim) Topic() string                            { return "NativeCmsPublicationEvents" }
+func (c *fakeGroupClaim) Partition() int32                         { return c.partition }
+func (c *fakeGroupClaim) InitialOffset() int64                     { return 0 }
+func (c *fakeGroupClaim) HighWaterMarkOffset() int64               { return 10 }
+func (c *fakeGroupClaim) Messages() <-chan *sarama.ConsumerMessage { return c.messages }
+
+func TestParseFTMessage(t *testing.T) {
+	var tests = []struct {
+		name     string
+		value    string
+		headers  []*sarama.RecordHeader
+		expected queueConsumer.Message
+	}{
+		{
+			"FT message",
+			"FTMSG/1.0\r\nMessage-Id: fc429b46-2500-4fe7-88bb-fd507fbaf00c\r\nX-Request-Id: tid_test\r\n\r\n{\"uuid\":\"7543220a-2389-11e5-bd83-71cb60e8f08c\"}",
+			nil,
+			queueConsumer.Message{Headers: map[string]string{"Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c", "X-Request-Id": "tid_test"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+		},
+		{
+			"FT message without headers",
+			"FTMSG/1.0\r\n\r\n{}",
+			[]*sarama.RecordHeader{{Key: []byte("X-Request-Id"), Value: []byte("tid_record")}},
+			queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_record"}, Body: `{}`},
+		},
+		{
+			"plain record",
+			`{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			[]*sarama.RecordHeader{{Key: []byte("Content-Type"), Value: []byte("application/json")}},
+			queueConsumer.Message{Headers: map[string]string{"Content-Type": "application/json"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+		},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseFTMessage([]byte(test.value), test.headers), test.name)
+	}
+}
+
+func TestParseFTMessageRoundTrip(t *testing.T) {
+	msg := queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json"}, Body: "{\r\n\r\n}"}
+	record := newKafkaRecord("topic", "", queueProducer.Message{Headers: msg.Headers, Body: msg.Body})
+	value, _ := record.Value.Encode()
+	assert.Equal(t, msg, parseFTMessage(value, nil))
+}
+
+func TestKafkaSourceConsumeClaim(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	var forwarded []sourcePosition
+	source := &kafkaGroupSource{
+		group: "kafka-bridge",
+		topic: "NativeCmsPublicationEvents",
+		forward: func(msg queueConsumer.Message, position *sourcePosition) {
+			assert.Equal(t, "tid_test", msg.Headers["X-Request-Id"])
+			forwarded = append(forwarded, *position)
+		},
+	}
+	session := &fakeGroupSession{ctx: context.Background(), claims: map[string][]int32{"NativeCmsPublicationEvents": {2, 0}}}
+	assert.NoError(t, source.Setup(session))
+
+	claim := &fakeGroupClaim{partition: 2, messages: make(chan *sarama.ConsumerMessage, 2)}
+	for _, offset := range []int64{7, 8} {
+		claim.messages <- &sarama.ConsumerMessage{Partition: 2, Offset: offset, Value: []byte("FTMSG/1.0\r\nX-Request-Id: tid_test\r\n\r\n{}")}
+	}
+	close(claim.messages)
+	assert.NoError(t, source.ConsumeClaim(session, claim))
+
+	assert.Equal(t, []sourcePosition{{partitioned: true, partition: 2, offset: 7}, {partitioned: true, partition: 2, offset: 8}}, forwarded)
+	assert.Equal(t, []int64{7, 8}, session.marked)
+	assert.Equal(t, 2, session.commits, "Offsets are committed after every message with no commit interval")
+
+	w := httptest.NewRecorder()
+	source.partitionsHandler(w, httptest.NewRequest("GET", partitionsPath, nil))
+	report := struct {
+		MemberID   string              `json:"memberId"`
+		Generation int32               `json:"generation"`
+		Partitions []partitionProgress `json:"partitions"`
+	}{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "member-1", report.MemberID)
+	assert.Equal(t, int32(3), report.Generation)
+	assert.Len(t, report.Partitions, 2)
+	assert.Equal(t, int32(0), report.Partitions[0].Partition)
+	assert.Equal(t, int64(-1), report.Partitions[0].Offset)
+	assert.Equal(t, int64(8), report.Partitions[1].Offset)
+	assert.Equal(t, int64(1), report.Partitions[1].Lag)
+
+	assert.NoError(t, source.Cleanup(session))
+	assert.Equal(t, 3, session.commits, "Offsets are committed when the partitions are revoked")
+	assert.Empty(t, source.partitions)
+}
+
+// tickingGroupSession reports every commit, as ConsumeClaim commits from its own goroutine
+type tickingGroupSession struct {
+	*fakeGroupSession
+	committed chan struct{}
+}
+
+func (s tickingGroupSession) Commit() { s.committed <- struct{}{} }
+
+func TestKafkaSourceCommitsOnInterval(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	source := &kafkaGroupSource{
+		group:          "kafka-bridge",
+		topic:          "NativeCmsPublicationEvents",
+		commitInterval: 20 * time.Millisecond,
+		forward:        func(queueConsumer.Message, *sourcePosition) {},
+		partitions:     make(map[int32]*partitionProgress),
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	session := tickingGroupSession{fakeGroupSession: &fakeGroupSession{ctx: ctx}, committed: make(chan struct{}, 10)}
+	claim := &fakeGroupClaim{partition: 2, messages: make(chan *sarama.ConsumerMessage, 1)}
+	claim.messages <- &sarama.ConsumerMessage{Partition: 2, Offset: 7, Value: []byte("{}")}
+
+	done := make(chan error)
+	go func() { done <- source.ConsumeClaim(session, claim) }()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-session.committed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("The offsets weren't committed on the commit interval without new messages")
+		}
+	}
+	cancel()
+	assert.NoError(t, <-done)
+	assert.Equal(t, []int64{7}, session.marked)
+}
+
+func TestKafkaSourceClose(t *testing.T) {
+	recorder := &closeRecorder{}
+	source := &kafkaGroupSource{client: recordingCloseClient{recorder: recorder}}
+	assert.ErrorIs(t, source.Close(), sarama.ErrClosedClient)
+	assert.Equal(t, []string{"client"}, recorder.closed)
+}
+
+func TestKafkaSourceConfig(t *testing.T) {
+	client := kafkaClientConfig{brokers: []string{"localhost:9092"}}
+
+	config, err := kafkaSourceConfig{client: client, offset: "smallest", rebalanceStrategy: "sticky"}.saramaConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, sarama.OffsetOldest, config.Consumer.Offsets.Initial)
+	assert.False(t, config.Consumer.Offsets.AutoCommit.Enable)
+
+	_, err = kafkaSourceConfig{client: client, offset: "middle"}.saramaConfig()
+	assert.Error(t, err)
+	_, err = kafkaSourceConfig{client: client, rebalanceStrategy: "random"}.saramaConfig()
+	assert.Error(t, err)
+}
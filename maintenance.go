@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const (
+	cronWindowPrefix           = "cron:"
+	maintenancePollInterval    = 10 * time.Second
+	maintenanceWindowSeparator = ";"
+)
+
+// maintenanceWindow is either a fixed period between two points in time,
+// or a recurring period of a given length started by a cron schedule.
+type maintenanceWindow struct {
+	start    time.Time
+	end      time.Time
+	schedule cron.Schedule
+	duration time.Duration
+}
+
+// maintenanceSchedule holds the windows during which the bridge must not consume messages.
+// A nil schedule never pauses the bridge.
+type maintenanceSchedule struct {
+	windows []maintenanceWindow
+}
+
+// parseMaintenanceWindows parses a semicolon separated list of windows. Each window is either
+// "<RFC3339 start>/<RFC3339 end>" or "cron:<cron expression>/<duration>", e.g.
+// "2026-10-20T01:00:00Z/2026-10-20T03:00:00Z;cron:0 2 * * SUN/2h".
+func parseMaintenanceWindows(spec string) (*maintenanceSchedule, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	schedule := &maintenanceSchedule{}
+	for _, entry := range strings.Split(spec, maintenanceWindowSeparator) {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		window, err := parseMaintenanceWindow(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid maintenance window '%s': %v", entry, err)
+		}
+		schedule.windows = append(schedule.windows, window)
+	}
+	return schedule, nil
+}
+
+func parseMaintenanceWindow(entry string) (maintenanceWindow, error) {
+	sep := strings.LastIndex(entry, "/")
+	if sep < 0 {
+		return maintenanceWindow{}, errors.New("expected '<start>/<end>' or 'cron:<expression>/<duration>'")
+	}
+	from, to := strings.TrimSpace(entry[:sep]), strings.TrimSpace(entry[sep+1:])
+
+	if strings.HasPrefix(from, cronWindowPrefix) {
+		schedule, err := cron.ParseStandard(strings.TrimPrefix(from, cronWindowPrefix))
+		if err != nil {
+			return maintenanceWindow{}, err
+		}
+		duration, err := time.ParseDuration(to)
+		if err != nil {
+			return maintenanceWindow{}, err
+		}
+		if duration <= 0 {
+			return maintenanceWindow{}, errors.New("the window duration must be positive")
+		}
+		return maintenanceWindow{schedule: schedule, duration: duration}, nil
+	}
+
+	start, err := time.Parse(time.RFC3339, from)
+	if err != nil {
+		return maintenanceWindow{}, err
+	}
+	end, err := time.Parse(time.RFC3339, to)
+	if err != nil {
+		return maintenanceWindow{}, err
+	}
+	if !end.After(start) {
+		return maintenanceWindow{}, errors.New("the window must end after it starts")
+	}
+	return maintenanceWindow{start: start, end: end}, nil
+}
+
+// activeAt returns the end of the window if t falls within it
+func (w maintenanceWindow) activeAt(t time.Time) (time.Time, bool) {
+	if w.schedule == nil {
+		return w.end, !t.Before(w.start) && t.Before(w.end)
+	}
+	// the latest start which could still cover t is the first one after t - duration,
+	// cron expressions are evaluated in UTC unless they specify CRON_TZ
+	start := w.schedule.Next(t.UTC().Add(-w.duration))
+	end := start.Add(w.duration)
+	return end, !start.After(t) && t.Before(end)
+}
+
+// activeWindow returns the end of the maintenance in progress at t, if any.
+// When overlapping windows are active, the latest end is returned.
+func (s *maintenanceSchedule) activeWindow(t time.Time) (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+	var latestEnd time.Time
+	active := false
+	for _, w := range s.windows {
+		if end, ok := w.activeAt(t); ok {
+			active = true
+			if end.After(latestEnd) {
+				latestEnd = end
+			}
+		}
+	}
+	return latestEnd, active
+}
+
+// pausedStatus returns the message reported by the health checks while a maintenance window is in progress
+func (s *maintenanceSchedule) pausedStatus(t time.Time) (string, bool) {
+	end, paused := s.activeWindow(t)
+	if !paused {
+		return "", false
+	}
+	return "Paused for maintenance until " + end.UTC().Format(time.RFC3339), true
+}
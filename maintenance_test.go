@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseMaintenanceWindows(t *testing.T) {
+	var tests = []struct {
+		spec            string
+		expectedWindows int
+		expectedErr     bool
+	}{
+		{"", 0, false},
+		{"2026-10-20T01:00:00Z/2026-10-20T03:00:00Z", 1, false},
+		{"cron:0 2 * * SUN/2h", 1, false},
+		{"cron:*/30 * * * */5m; 2026-10-20T01:00:00Z/2026-10-20T03:00:00Z;", 2, false},
+		{"2026-10-20T03:00:00Z/2026-10-20T01:00:00Z", 0, true},
+		{"2026-10-20T01:00:00Z", 0, true},
+		{"cron:0 2 * * SUN/-2h", 0, true},
+		{"cron:not a cron/2h", 0, true},
+	}
+
+	for _, test := range tests {
+		schedule, err := parseMaintenanceWindows(test.spec)
+		if test.expectedErr {
+			assert.Error(t, err, test.spec)
+			continue
+		}
+		assert.NoError(t, err, test.spec)
+		if test.expectedWindows == 0 {
+			assert.Nil(t, schedule)
+		} else {
+			assert.Len(t, schedule.windows, test.expectedWindows, test.spec)
+		}
+	}
+}
+
+func TestMaintenanceScheduleActiveWindow(t *testing.T) {
+	schedule, err := parseMaintenanceWindows("2026-10-20T01:00:00Z/2026-10-20T03:00:00Z;cron:0 2 * * SUN/2h")
+	assert.NoError(t, err)
+
+	var tests = []struct {
+		at             string
+		expectedPaused bool
+		expectedEnd    string
+	}{
+		{"2026-10-20T00:59:59Z", false, ""},
+		{"2026-10-20T01:00:00Z", true, "2026-10-20T03:00:00Z"},
+		{"2026-10-20T02:59:59Z", true, "2026-10-20T03:00:00Z"},
+		{"2026-10-20T03:00:00Z", false, ""},
+		// 2026-10-18 is a Sunday
+		{"2026-10-18T01:59:59Z", false, ""},
+		{"2026-10-18T02:00:00Z", true, "2026-10-18T04:00:00Z"},
+		{"2026-10-18T03:30:00Z", true, "2026-10-18T04:00:00Z"},
+		{"2026-10-18T04:00:00Z", false, ""},
+		{"2026-10-19T03:00:00Z", false, ""},
+	}
+
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.at)
+		end, paused := schedule.activeWindow(at.In(time.UTC))
+		assert.Equal(t, test.expectedPaused, paused, test.at)
+		if test.expectedPaused {
+			assert.Equal(t, test.expectedEnd, end.UTC().Format(time.RFC3339), test.at)
+		}
+	}
+}
+
+func TestNilMaintenanceScheduleNeverPauses(t *testing.T) {
+	var schedule *maintenanceSchedule
+	_, paused := schedule.pausedStatus(time.Now())
+	assert.False(t, paused)
+}
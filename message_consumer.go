@@ -9,13 +9,20 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Financial-Times/go-logger"
 	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
 )
 
+// messageSource is a source consumed natively rather than through the kafka proxy
+type messageSource interface {
+	// consumer returns a consumer which consumes until it is stopped, a new one is needed after every stop
+	consumer() queueConsumer.MessageConsumer
+}
+
 func (bridge BridgeApp) consumeMessages() {
 	consumerConfig := bridge.consumerConfig
 
-	consumer := queueConsumer.NewAgeingConsumer(*consumerConfig, bridge.forwardMsg, queueConsumer.AgeingClient{
+	ageingClient := queueConsumer.AgeingClient{
 		Client: &http.Client{
 			Timeout: 60 * time.Second,
 			Transport: &http.Transport{
@@ -26,19 +33,82 @@ func (bridge BridgeApp) consumeMessages() {
 			},
 		},
 		MaxAge: time.Duration(2) * time.Minute,
-	})
+	}
+	ageingClient.StartAgeingProcess()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
 
-	var wg sync.WaitGroup
-	wg.Add(1)
+	for {
+		if status, paused := bridge.maintenance.pausedStatus(time.Now()); paused {
+			logger.Infof(nil, status)
+			if stopped := waitUntilResumed(ch, bridge.maintenance); stopped {
+				return
+			}
+			logger.Infof(nil, "Maintenance window is over, resuming consumption")
+			continue
+		}
 
-	go func() {
-		consumer.Start()
-		wg.Done()
-	}()
+		// A new consumer instance is created on every resume, the group and its committed offsets are kept.
+		var consumer queueConsumer.MessageConsumer
+		if bridge.source != nil {
+			consumer = bridge.source.consumer()
+		} else {
+			consumer = queueConsumer.NewBatchedConsumer(*consumerConfig, bridge.forwardBatch, ageingClient.Client)
+		}
 
-	ch := make(chan os.Signal)
-	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
-	<-ch
-	consumer.Stop()
-	wg.Wait()
+		var wg sync.WaitGroup
+		wg.Add(1)
+
+		go func() {
+			consumer.Start()
+			wg.Done()
+		}()
+
+		stopped := waitUntilPaused(ch, bridge.maintenance)
+		consumer.Stop()
+		wg.Wait()
+		if stopped {
+			return
+		}
+	}
+}
+
+// waitUntilPaused blocks until either a maintenance window starts or the process is signalled to stop.
+// It returns true if the process should stop.
+func waitUntilPaused(ch chan os.Signal, maintenance *maintenanceSchedule) bool {
+	if maintenance == nil {
+		<-ch
+		return true
+	}
+
+	ticker := time.NewTicker(maintenancePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ch:
+			return true
+		case now := <-ticker.C:
+			if _, paused := maintenance.activeWindow(now); paused {
+				return false
+			}
+		}
+	}
+}
+
+// waitUntilResumed blocks until the maintenance in progress is over or the process is signalled to stop.
+// It returns true if the process should stop.
+func waitUntilResumed(ch chan os.Signal, maintenance *maintenanceSchedule) bool {
+	ticker := time.NewTicker(maintenancePollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ch:
+			return true
+		case now := <-ticker.C:
+			if _, paused := maintenance.activeWindow(now); !paused {
+				return false
+			}
+		}
+	}
 }
@@ -2,31 +2,125 @@ package main
 
 import (
 	"errors"
+	"time"
+
 	"github.com/Financial-Times/go-logger"
 	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
 	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
-	"github.com/dchest/uniuri"
 )
 
 const tidValidRegexp = "(tid|SYNTHETIC-REQ-MON)[a-zA-Z0-9_-]*$"
 
-func (bridge BridgeApp) forwardMsg(msg queueConsumer.Message) {
+// forwardBatch forwards a consumed batch lane by lane, so that higher priority messages are not held up
+// behind a backlog of lower priority ones. The offsets are committed once the whole batch has been handled,
+// so it only returns once the outcome of every message is known.
+func (bridge BridgeApp) forwardBatch(msgs []queueConsumer.Message) {
+	var pending []*pendingForward
+	for lane, laneMsgs := range bridge.priorityLanes.split(msgs) {
+		if len(laneMsgs) > 0 && len(laneMsgs) < len(msgs) {
+			logger.Debugf(map[string]interface{}{"lane": bridge.priorityLanes.laneName(lane), "messages": len(laneMsgs)}, "Forwarding priority lane")
+		}
+		for _, msg := range laneMsgs {
+			pending = append(pending, bridge.dispatch(msg, nil))
+		}
+	}
+	for _, p := range pending {
+		p.wait()
+	}
+}
+
+// forward sends a single message to the producer and waits for the outcome,
+// position is only known for the sources exposing it
+func (bridge BridgeApp) forward(msg queueConsumer.Message, position *sourcePosition) {
+	bridge.dispatch(msg, position).wait()
+}
+
+// dispatch hands a message over to the producer, without waiting for the outcome when the producer batches messages.
+// It returns nil when the message is not forwarded.
+func (bridge BridgeApp) dispatch(msg queueConsumer.Message, position *sourcePosition) *pendingForward {
+	receivedAt := time.Now()
+	if !bridge.preserveHeaderCase {
+		messageHeaders(msg.Headers).canonicalize()
+	}
 	tid, err := extractTID(msg.Headers)
 	if err != nil {
-		tid = "tid_" + uniuri.NewLen(10) + "_kafka_bridge"
+		tid = newBridgeTID()
 		logger.NewEntry(tid).Info("Couldn't extract transaction id, due to %s. TID was generated.", err.Error())
+	} else if validTID, err := bridge.tidValidation.enforce(msg.Headers, tid); err != nil {
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	} else if validTID != tid {
+		logger.NewEntry(validTID).Info("Transaction id " + tid + " is invalid. TID was regenerated.")
+		tid = validTID
 	}
-	msg.Headers["X-Request-Id"] = tid
-	err = bridge.producerInstance.SendMessage("", queueProducer.Message{Headers: msg.Headers, Body: msg.Body})
+	messageHeaders(msg.Headers).Set("X-Request-Id", tid)
+
+	if msg.Body, err = bridge.claimCheck.rehydrate(msg.Headers, msg.Body); err != nil {
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	}
+
+	if err = bridge.schemaValidator.validate(msg.Headers, msg.Body); err != nil {
+		bridge.deadLetter(tid, msg, "schema validation failed: "+err.Error())
+		return nil
+	}
+
+	body, err := bridge.bodyTransforms.apply(tid, msg.Body)
+	if err != nil {
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	}
+
+	body, err = bridge.redaction.apply(tid, msg.Headers, body)
 	if err != nil {
-		logger.NewMonitoringEntry("Forwarding", tid, "").Error("Error happened during message forwarding: " + err.Error())
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	}
+
+	forwardedAt := time.Now()
+	bridge.provenance.stamp(msg.Headers, position, forwardedAt)
+	bridge.timestamps.stamp(msg.Headers, receivedAt, forwardedAt)
+
+	body, err = bridge.claimCheck.checkIn(msg.Headers, body)
+	if err != nil {
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	}
+
+	body, err = bridge.cloudEvents.encode(msg.Headers, body)
+	if err != nil {
+		bridge.deadLetter(tid, msg, err.Error())
+		return nil
+	}
+
+	message := queueProducer.Message{Headers: msg.Headers, Body: body}
+	if async, ok := bridge.producerInstance.(asyncProducer); ok {
+		return &pendingForward{tid: tid, outcome: async.sendAsync(message)}
+	}
+	outcome := make(chan error, 1)
+	outcome <- bridge.producerInstance.SendMessage("", message)
+	return &pendingForward{tid: tid, outcome: outcome}
+}
+
+// pendingForward is a message handed over to the producer, whose outcome may not be known yet
+type pendingForward struct {
+	tid     string
+	outcome <-chan error
+}
+
+func (p *pendingForward) wait() {
+	if p == nil {
+		return
+	}
+	if err := <-p.outcome; err != nil {
+		logger.NewMonitoringEntry("Forwarding", p.tid, "").Error("Error happened during message forwarding: " + err.Error())
 	} else {
-		logger.NewMonitoringEntry("Forwarding", tid, "").Info("Message has been forwarded")
+		logger.NewMonitoringEntry("Forwarding", p.tid, "").Info("Message has been forwarded")
 	}
 }
 
 func extractTID(headers map[string]string) (string, error) {
-	header := headers["X-Request-Id"]
+	header := messageHeaders(headers).Get("X-Request-Id")
 	if header == "" {
 		return "", errors.New("X-Request-Id header could not be found.")
 	}
@@ -53,6 +53,17 @@ func TestExtractTID(t *testing.T) {
 			"t9happe59y",
 			"",
 		},
+		{
+			queueConsumer.Message{
+				Headers: map[string]string{
+					"message-id":   "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+					"content-type": "application/json",
+					"x-request-id": "tid_lowercase",
+				},
+				Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","type":"EOM::CompoundStory","value":"test"}`},
+			"tid_lowercase",
+			"",
+		},
 	}
 
 	for _, test := range tests {
@@ -0,0 +1,71 @@
+package main
+
+import "net/textproto"
+
+const (
+	headerCaseCanonical = "canonical"
+	headerCasePreserve  = "preserve"
+)
+
+// messageHeaders gives case-insensitive access to message headers, as producers don't agree on the case
+// of the header names (X-Request-Id, x-request-id). The canonical name is looked up first, so that
+// canonicalised headers are found without scanning the whole map.
+type messageHeaders map[string]string
+
+func canonicalHeaderName(name string) string {
+	return textproto.CanonicalMIMEHeaderKey(name)
+}
+
+// Lookup returns the value of the header whatever the case of its name
+func (h messageHeaders) Lookup(name string) (string, bool) {
+	if value, found := h[name]; found {
+		return value, true
+	}
+	canonical := canonicalHeaderName(name)
+	if value, found := h[canonical]; found {
+		return value, true
+	}
+	for key, value := range h {
+		if canonicalHeaderName(key) == canonical {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// Get returns the value of the header whatever the case of its name, or "" when it is missing
+func (h messageHeaders) Get(name string) string {
+	value, _ := h.Lookup(name)
+	return value
+}
+
+// Set replaces the header, including the variants of its name in another case
+func (h messageHeaders) Set(name string, value string) {
+	h.Del(name)
+	h[name] = value
+}
+
+// Del removes the header whatever the case of its name
+func (h messageHeaders) Del(name string) {
+	canonical := canonicalHeaderName(name)
+	for key := range h {
+		if key == name || canonicalHeaderName(key) == canonical {
+			delete(h, key)
+		}
+	}
+}
+
+// canonicalize renames the headers to their canonical form, e.g. x-request-id to X-Request-Id.
+// When several names only differ by their case, the value of the canonical one wins.
+func (h messageHeaders) canonicalize() {
+	for key, value := range h {
+		canonical := canonicalHeaderName(key)
+		if canonical == key {
+			continue
+		}
+		delete(h, key)
+		if _, found := h[canonical]; !found {
+			h[canonical] = value
+		}
+	}
+}
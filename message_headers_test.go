@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessageHeadersLookup(t *testing.T) {
+	headers := messageHeaders{"x-request-id": "tid_test", "Content-Type": "application/json"}
+
+	value, found := headers.Lookup("X-Request-Id")
+	assert.True(t, found)
+	assert.Equal(t, "tid_test", value)
+	assert.Equal(t, "application/json", headers.Get("content-type"))
+	_, found = headers.Lookup("Message-Id")
+	assert.False(t, found)
+
+	headers.Set("X-Request-Id", "tid_replaced")
+	assert.Equal(t, messageHeaders{"X-Request-Id": "tid_replaced", "Content-Type": "application/json"}, headers)
+
+	headers.Del("CONTENT-TYPE")
+	assert.Equal(t, messageHeaders{"X-Request-Id": "tid_replaced"}, headers)
+}
+
+func TestMessageHeadersCanonicalize(t *testing.T) {
+	headers := messageHeaders{
+		"x-request-id":      "tid_test",
+		"message-timestamp": "2015-07-06T07:03:09.362Z",
+		"Content-Type":      "application/json",
+		"content-type":      "text/plain",
+	}
+	headers.canonicalize()
+	assert.Equal(t, messageHeaders{
+		"X-Request-Id":      "tid_test",
+		"Message-Timestamp": "2015-07-06T07:03:09.362Z",
+		"Content-Type":      "application/json",
+	}, headers)
+}
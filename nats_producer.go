@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsRequestTimeout = 30 * time.Second
+
+	// natsMsgIDHeader is the header JetStream deduplicates on, within the duplicate window of the stream
+	natsMsgIDHeader = "Nats-Msg-Id"
+)
+
+// natsClientConfig holds the connection settings shared by the NATS producer and source
+type natsClientConfig struct {
+	name      string
+	credsFile string
+}
+
+// natsProducerConfig holds the settings specific to the nats producer
+type natsProducerConfig struct {
+	client  natsClientConfig
+	subject string
+}
+
+// connectJetStream connects to the comma separated NATS servers, reconnecting for ever when the connection is lost
+func connectJetStream(servers string, config natsClientConfig) (*nats.Conn, jetstream.JetStream, error) {
+	if servers == "" {
+		return nil, nil, errors.New("the NATS servers are required")
+	}
+	options := []nats.Option{nats.Name(config.name), nats.MaxReconnects(-1)}
+	if config.credsFile != "" {
+		options = append(options, nats.UserCredentials(config.credsFile))
+	}
+	conn, err := nats.Connect(servers, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	js, err := jetstream.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, js, nil
+}
+
+// natsMessageProducer publishes the messages to a JetStream subject. The message headers are set as NATS headers
+// and the Message-Id as the Nats-Msg-Id, so that JetStream drops the messages bridged twice.
+type natsMessageProducer struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	subject string
+}
+
+func newNATSMessageProducer(servers string, subject string, config natsClientConfig) (*natsMessageProducer, error) {
+	if subject == "" {
+		return nil, errors.New("the NATS subject is required")
+	}
+	conn, js, err := connectJetStream(servers, config)
+	if err != nil {
+		return nil, err
+	}
+	return &natsMessageProducer{conn: conn, js: js, subject: subject}, nil
+}
+
+func (p *natsMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), natsRequestTimeout)
+	defer cancel()
+	if _, err := p.js.PublishMsg(ctx, newNATSMsg(p.subject, message), natsPublishOptions(message)...); err != nil {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+	}
+	return nil
+}
+
+// SendBatch publishes the messages without waiting in between, then waits for the acknowledgement of each one
+func (p *natsMessageProducer) SendBatch(messages []queueProducer.Message) []error {
+	errs := make([]error, len(messages))
+	futures := make([]jetstream.PubAckFuture, len(messages))
+	for i, message := range messages {
+		futures[i], errs[i] = p.js.PublishMsgAsync(newNATSMsg(p.subject, message), natsPublishOptions(message)...)
+	}
+
+	timeout := time.After(natsRequestTimeout)
+	for i, future := range futures {
+		if future == nil {
+			continue
+		}
+		select {
+		case <-future.Ok():
+		case errs[i] = <-future.Err():
+		case <-timeout:
+			errs[i] = errors.New("timed out waiting for the JetStream acknowledgement")
+		}
+	}
+	for i, err := range errs {
+		if err != nil {
+			errs[i] = fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(messages[i].Headers).Get("X-Request-Id"), err)
+		}
+	}
+	return errs
+}
+
+// ConnectivityCheck looks up the stream capturing the subject, which fails when the servers are unreachable
+// or no stream would store the messages
+func (p *natsMessageProducer) ConnectivityCheck() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), natsRequestTimeout)
+	defer cancel()
+	if _, err := p.js.StreamNameBySubject(ctx, p.subject); err != nil {
+		return "Forwarding messages is broken. Couldn't find the JetStream stream of subject " + p.subject, err
+	}
+	return "Connectivity to JetStream is OK", nil
+}
+
+// Close flushes the pending publishes and closes the connection
+func (p *natsMessageProducer) Close() error {
+	return p.conn.Drain()
+}
+
+func newNATSMsg(subject string, message queueProducer.Message) *nats.Msg {
+	msg := nats.NewMsg(subject)
+	for _, name := range sortedHeaderNames(message.Headers) {
+		msg.Header.Set(name, message.Headers[name])
+	}
+	msg.Data = []byte(message.Body)
+	return msg
+}
+
+func natsPublishOptions(message queueProducer.Message) []jetstream.PublishOpt {
+	if id := messageHeaders(message.Headers).Get("Message-Id"); id != "" {
+		return []jetstream.PublishOpt{jetstream.WithMsgID(id)}
+	}
+	return nil
+}
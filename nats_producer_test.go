@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNATSMsgRoundTrip(t *testing.T) {
+	message := queueProducer.Message{
+		Headers: map[string]string{
+			"Message-Id":   "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+			"X-Request-Id": "tid_test",
+			"Content-Type": "application/json",
+		},
+		Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+	}
+	msg := newNATSMsg("upp.publications", message)
+	assert.Equal(t, "upp.publications", msg.Subject)
+	assert.Equal(t, "tid_test", msg.Header.Get("X-Request-Id"))
+	assert.Len(t, natsPublishOptions(message), 1, "The Message-Id is the JetStream dedupe id")
+	assert.Empty(t, natsPublishOptions(queueProducer.Message{Headers: map[string]string{}}))
+
+	assert.Equal(t, queueConsumer.Message{Headers: message.Headers, Body: message.Body}, parseNATSMsg(msg.Header, msg.Data))
+}
+
+func TestParseNATSMsg(t *testing.T) {
+	var tests = []struct {
+		name     string
+		header   nats.Header
+		expected map[string]string
+	}{
+		{
+			"dedupe id without Message-Id",
+			nats.Header{natsMsgIDHeader: {"fc429b46-2500-4fe7-88bb-fd507fbaf00c"}, "X-Request-Id": {"tid_test"}},
+			map[string]string{"Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c", "X-Request-Id": "tid_test"},
+		},
+		{
+			"dedupe id with Message-Id",
+			nats.Header{natsMsgIDHeader: {"other"}, "Message-Id": {"fc429b46-2500-4fe7-88bb-fd507fbaf00c"}},
+			map[string]string{"Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+		},
+		{
+			"multiple values",
+			nats.Header{"X-Request-Id": {"tid_first", "tid_second"}},
+			map[string]string{"X-Request-Id": "tid_first"},
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.expected, parseNATSMsg(test.header, []byte("{}")).Headers, test.name)
+	}
+}
+
+// TestNATSIntegration runs against a local nats-server started with JetStream enabled (nats-server -js)
+// when NATS_TEST_URL is set, e.g. nats://localhost:4222
+func TestNATSIntegration(t *testing.T) {
+	url := os.Getenv("NATS_TEST_URL")
+	if url == "" {
+		t.Skip("NATS_TEST_URL is not set")
+	}
+	logger.InitDefaultLogger("kafka-bridge")
+	name := "kafka-bridge-test-" + strings.ToLower(time.Now().UTC().Format("20060102T150405"))
+	subject := name + ".publications"
+
+	conn, js, err := connectJetStream(url, natsClientConfig{name: "kafka-bridge-test"})
+	assert.NoError(t, err)
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err = js.CreateStream(ctx, jetstream.StreamConfig{Name: name, Subjects: []string{subject}})
+	assert.NoError(t, err)
+	defer js.DeleteStream(context.Background(), name)
+
+	p, err := newNATSMessageProducer(url, subject, natsClientConfig{name: "kafka-bridge-test"})
+	assert.NoError(t, err)
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+	message := queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_integration", "Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+		Body:    `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`,
+	}
+	assert.NoError(t, p.SendMessage("", message))
+	assert.NoError(t, p.SendMessage("", message), "Duplicates are acknowledged")
+	second := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_second", "Message-Id": "0b3c1f0e-3d52-4a4e-8d4c-3e8f2f0b7a11"}, Body: `{}`}
+	assert.Equal(t, []error{nil}, p.SendBatch([]queueProducer.Message{second}))
+
+	forwarded := make(chan queueConsumer.Message, 3)
+	source, err := newNATSStreamSource(url, natsSourceConfig{client: natsClientConfig{name: "kafka-bridge-test"}, durable: "kafka-bridge", stream: name, offset: "smallest"},
+		func(msg queueConsumer.Message, position *sourcePosition) { forwarded <- msg })
+	assert.NoError(t, err)
+	_, err = source.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	consumer := source.consumer()
+	go consumer.Start()
+	defer consumer.Stop()
+	for _, tid := range []string{"tid_integration", "tid_second"} {
+		select {
+		case msg := <-forwarded:
+			assert.Equal(t, tid, msg.Headers["X-Request-Id"], "The duplicate was dropped by JetStream")
+		case <-time.After(10 * time.Second):
+			t.Fatal("The message wasn't consumed")
+		}
+	}
+	assert.NoError(t, p.Close())
+}
+
+// failingMessages is an iterator whose Next fails until it is stopped
+type failingMessages struct {
+	jetstream.MessagesContext
+	nexts   int32
+	stopped int32
+}
+
+func (m *failingMessages) Next() (jetstream.Msg, error) {
+	atomic.AddInt32(&m.nexts, 1)
+	if atomic.LoadInt32(&m.stopped) > 0 {
+		return nil, jetstream.ErrMsgIteratorClosed
+	}
+	return nil, errors.New("nats: connection closed")
+}
+
+func (m *failingMessages) Stop() {
+	atomic.AddInt32(&m.stopped, 1)
+}
+
+func TestNATSSourceStopsFailingIterator(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	source := &natsStreamSource{stream: "UPP", durable: "kafka-bridge"}
+	messages := &failingMessages{}
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	source.forwardAll(ctx, messages)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&messages.nexts), "The iterator isn't read again after an error")
+	assert.GreaterOrEqual(t, atomic.LoadInt32(&messages.stopped), int32(1), "The failing iterator is stopped")
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const natsSourceRetryInterval = 5 * time.Second
+
+// natsSourceConfig holds the settings of the JetStream source
+type natsSourceConfig struct {
+	client  natsClientConfig
+	durable string
+	stream  string
+	subject string
+	offset  string
+}
+
+// natsStreamSource consumes a JetStream stream through a durable pull consumer, so that several bridge
+// instances share the messages and a restart carries on from the last acknowledged one. Each message is
+// acknowledged once it has been forwarded.
+type natsStreamSource struct {
+	conn    *nats.Conn
+	js      jetstream.JetStream
+	stream  string
+	durable string
+	pull    jetstream.Consumer
+	forward func(msg queueConsumer.Message, position *sourcePosition)
+}
+
+func newNATSStreamSource(servers string, config natsSourceConfig, forward func(queueConsumer.Message, *sourcePosition)) (*natsStreamSource, error) {
+	if config.durable == "" || config.stream == "" {
+		return nil, errors.New("the NATS source requires a durable consumer name and a stream")
+	}
+	consumerConfig := jetstream.ConsumerConfig{
+		Durable:       config.durable,
+		FilterSubject: config.subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+	}
+	switch config.offset {
+	case "", "largest", "latest", "newest":
+		consumerConfig.DeliverPolicy = jetstream.DeliverNewPolicy
+	case "smallest", "earliest", "oldest":
+		consumerConfig.DeliverPolicy = jetstream.DeliverAllPolicy
+	default:
+		return nil, errors.New("unknown consumer offset " + config.offset)
+	}
+
+	conn, js, err := connectJetStream(servers, config.client)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), natsRequestTimeout)
+	defer cancel()
+	// the deliver policy of an existing consumer can't be changed, it only applies when the consumer is created
+	consumer, err := js.Consumer(ctx, config.stream, config.durable)
+	if errors.Is(err, jetstream.ErrConsumerNotFound) {
+		consumer, err = js.CreateConsumer(ctx, config.stream, consumerConfig)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &natsStreamSource{conn: conn, js: js, stream: config.stream, durable: config.durable, pull: consumer, forward: forward}, nil
+}
+
+// consumer returns a member of the durable consumer which consumes until it is stopped
+func (s *natsStreamSource) consumer() queueConsumer.MessageConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &natsStreamConsumer{source: s, ctx: ctx, cancel: cancel}
+}
+
+// ConnectivityCheck requests the consumer info, which fails when the servers are unreachable or the consumer was deleted
+func (s *natsStreamSource) ConnectivityCheck() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), natsRequestTimeout)
+	defer cancel()
+	if _, err := s.js.Consumer(ctx, s.stream, s.durable); err != nil {
+		return "Consuming messages is broken. Couldn't get the consumer " + s.durable + " of stream " + s.stream, err
+	}
+	return "Connectivity to JetStream is OK", nil
+}
+
+func (s *natsStreamSource) consume(ctx context.Context) {
+	for ctx.Err() == nil {
+		messages, err := s.pull.Messages()
+		if err != nil {
+			logger.Errorf(map[string]interface{}{"stream": s.stream, "consumer": s.durable}, err, "Consuming from JetStream failed, retrying")
+			select {
+			case <-ctx.Done():
+			case <-time.After(natsSourceRetryInterval):
+			}
+			continue
+		}
+		s.forwardAll(ctx, messages)
+	}
+}
+
+// forwardAll forwards the messages until the iterator is stopped. The iterator is stopped on the first error
+// other than its closing, and consume creates a new one after natsSourceRetryInterval.
+func (s *natsStreamSource) forwardAll(ctx context.Context, messages jetstream.MessagesContext) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			messages.Stop()
+		case <-done:
+		}
+	}()
+
+	for {
+		msg, err := messages.Next()
+		if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+			return
+		}
+		if err != nil {
+			logger.Errorf(map[string]interface{}{"stream": s.stream, "consumer": s.durable}, err, "Couldn't get the next JetStream message, retrying")
+			messages.Stop()
+			select {
+			case <-ctx.Done():
+			case <-time.After(natsSourceRetryInterval):
+			}
+			return
+		}
+		var position *sourcePosition
+		if metadata, err := msg.Metadata(); err == nil {
+			position = &sourcePosition{offset: int64(metadata.Sequence.Stream)}
+		}
+		s.forward(parseNATSMsg(msg.Headers(), msg.Data()), position)
+		if err := msg.Ack(); err != nil {
+			logger.Errorf(map[string]interface{}{"stream": s.stream, "consumer": s.durable}, err, "Couldn't acknowledge the JetStream message")
+		}
+	}
+}
+
+// natsStreamConsumer is a single run of the durable consumer, from Start until Stop
+type natsStreamConsumer struct {
+	source *natsStreamSource
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (c *natsStreamConsumer) Start() {
+	c.source.consume(c.ctx)
+}
+
+func (c *natsStreamConsumer) Stop() {
+	c.cancel()
+}
+
+func (c *natsStreamConsumer) ConnectivityCheck() (string, error) {
+	return c.source.ConnectivityCheck()
+}
+
+// parseNATSMsg reads the FT message headers from the NATS headers, the Nats-Msg-Id standing for a missing Message-Id
+func parseNATSMsg(header nats.Header, data []byte) queueConsumer.Message {
+	msg := queueConsumer.Message{Headers: make(map[string]string), Body: string(data)}
+	for name, values := range header {
+		if len(values) > 0 && name != natsMsgIDHeader {
+			msg.Headers[name] = values[0]
+		}
+	}
+	if id := header.Get(natsMsgIDHeader); id != "" {
+		if _, found := messageHeaders(msg.Headers).Lookup("Message-Id"); !found {
+			msg.Headers["Message-Id"] = id
+		}
+	}
+	return msg
+}
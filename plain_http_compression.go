@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+
+	compressionProbeInterval = 10 * time.Minute
+)
+
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+// requestCompression compresses plainHTTP request bodies above the threshold.
+// When probing is enabled the destination must advertise the encoding in the Accept-Encoding header
+// of its OPTIONS response (RFC 7694), and compression is turned off whenever the destination answers 415.
+// A nil requestCompression sends every body raw.
+type requestCompression struct {
+	encoding  string
+	threshold int
+	probe     bool
+
+	sync.Mutex
+	supported bool
+	checkedAt time.Time
+}
+
+func newRequestCompression(encoding string, threshold int, probe bool) (*requestCompression, error) {
+	switch encoding {
+	case "":
+		return nil, nil
+	case compressionGzip, compressionZstd:
+		return &requestCompression{encoding: encoding, threshold: threshold, probe: probe}, nil
+	default:
+		return nil, errors.New("unsupported request compression " + encoding)
+	}
+}
+
+// forDestination returns a copy of the settings without the probing state, which is specific to each destination
+func (rc *requestCompression) forDestination() *requestCompression {
+	if rc == nil {
+		return nil
+	}
+	return &requestCompression{encoding: rc.encoding, threshold: rc.threshold, probe: rc.probe}
+}
+
+// contentEncoding returns the encoding to use for a body of the given size sent to url, or "" to send it raw
+func (rc *requestCompression) contentEncoding(client plainHttpClient, url string, authorization string, size int) string {
+	if rc == nil || size < rc.threshold {
+		return ""
+	}
+	if !rc.probe {
+		return rc.encoding
+	}
+
+	rc.Lock()
+	defer rc.Unlock()
+	if rc.checkedAt.IsZero() || time.Since(rc.checkedAt) > compressionProbeInterval {
+		rc.supported = probeRequestEncoding(client, url, authorization, rc.encoding)
+		rc.checkedAt = time.Now()
+		logger.Infof(map[string]interface{}{"encoding": rc.encoding, "supported": rc.supported}, "Probed request compression support of "+url)
+	}
+	if rc.supported {
+		return rc.encoding
+	}
+	return ""
+}
+
+// unsupported records that the destination rejected a compressed body, until the next probe
+func (rc *requestCompression) unsupported() {
+	rc.Lock()
+	defer rc.Unlock()
+	rc.supported = false
+	rc.checkedAt = time.Now()
+}
+
+func probeRequestEncoding(client plainHttpClient, url string, authorization string, encoding string) bool {
+	req, err := http.NewRequest("OPTIONS", url, nil)
+	if err != nil {
+		return false
+	}
+	if len(authorization) > 0 {
+		req.Header.Add("Authorization", authorization)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode >= 300 {
+		return false
+	}
+	for _, accepted := range strings.Split(strings.Join(resp.Header.Values("Accept-Encoding"), ","), ",") {
+		if coding := strings.TrimSpace(strings.SplitN(accepted, ";", 2)[0]); strings.EqualFold(coding, encoding) {
+			return true
+		}
+	}
+	return false
+}
+
+func compressBody(encoding string, body string) ([]byte, error) {
+	switch encoding {
+	case compressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write([]byte(body)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case compressionZstd:
+		return zstdEncoder.EncodeAll([]byte(body), nil), nil
+	default:
+		return []byte(body), nil
+	}
+}
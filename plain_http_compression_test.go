@@ -0,0 +1,124 @@
+package main
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+type receivedRequest struct {
+	method   string
+	encoding string
+	body     string
+}
+
+// newCompressionTestServer returns a destination which advertises and accepts the given encodings
+func newCompressionTestServer(t *testing.T, acceptedEncodings string) (*httptest.Server, *[]receivedRequest) {
+	var mu sync.Mutex
+	var received []receivedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Method == "OPTIONS" {
+			if acceptedEncodings != "" {
+				w.Header().Set("Accept-Encoding", acceptedEncodings)
+			}
+			received = append(received, receivedRequest{method: r.Method})
+			return
+		}
+
+		encoding := r.Header.Get("Content-Encoding")
+		if encoding != "" && !strings.Contains(acceptedEncodings, encoding) {
+			received = append(received, receivedRequest{method: r.Method, encoding: encoding})
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+
+		var body []byte
+		var err error
+		switch encoding {
+		case compressionGzip:
+			var gz *gzip.Reader
+			gz, err = gzip.NewReader(r.Body)
+			assert.NoError(t, err)
+			body, err = ioutil.ReadAll(gz)
+		case compressionZstd:
+			var zr *zstd.Decoder
+			zr, err = zstd.NewReader(r.Body)
+			assert.NoError(t, err)
+			body, err = ioutil.ReadAll(zr)
+			zr.Close()
+		default:
+			body, err = ioutil.ReadAll(r.Body)
+		}
+		assert.NoError(t, err)
+		received = append(received, receivedRequest{method: r.Method, encoding: encoding, body: string(body)})
+	}))
+	return server, &received
+}
+
+func TestPlainHTTPCompression(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	largeBody := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","value":"` + strings.Repeat("test ", 500) + `"}`
+	smallBody := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`
+
+	var tests = []struct {
+		name              string
+		encoding          string
+		probe             bool
+		acceptedEncodings string
+		body              string
+		expected          []receivedRequest
+	}{
+		{"below threshold", compressionGzip, true, "gzip", smallBody, []receivedRequest{{"POST", "", smallBody}}},
+		{"probed gzip", compressionGzip, true, "gzip, deflate", largeBody, []receivedRequest{{"OPTIONS", "", ""}, {"POST", "gzip", largeBody}}},
+		{"probed zstd", compressionZstd, true, "zstd;q=1.0", largeBody, []receivedRequest{{"OPTIONS", "", ""}, {"POST", "zstd", largeBody}}},
+		{"not advertised", compressionGzip, true, "", largeBody, []receivedRequest{{"OPTIONS", "", ""}, {"POST", "", largeBody}}},
+		{"rejected without probe", compressionZstd, false, "gzip", largeBody, []receivedRequest{{"POST", "zstd", ""}, {"POST", "", largeBody}}},
+	}
+
+	for _, test := range tests {
+		server, received := newCompressionTestServer(t, test.acceptedEncodings)
+		compression, err := newRequestCompression(test.encoding, 1024, test.probe)
+		assert.NoError(t, err)
+
+		p := newPlainHTTPMessageProducer(queueProducer.MessageProducerConfig{Addr: server.URL}, plainHTTPConfig{compression: compression})
+		err = p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: test.body})
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expected, *received, test.name)
+		server.Close()
+	}
+}
+
+func TestPlainHTTPCompressionProbeIsCached(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	server, received := newCompressionTestServer(t, "gzip")
+	defer server.Close()
+
+	compression, err := newRequestCompression(compressionGzip, 0, true)
+	assert.NoError(t, err)
+	p := newPlainHTTPMessageProducer(queueProducer.MessageProducerConfig{Addr: server.URL}, plainHTTPConfig{compression: compression})
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: "{}"}))
+	}
+	assert.Len(t, *received, 4)
+	assert.True(t, compression.checkedAt.IsZero(), "The configured settings must not hold the destination state")
+}
+
+func TestNewRequestCompressionInvalid(t *testing.T) {
+	_, err := newRequestCompression("br", 0, false)
+	assert.Error(t, err)
+
+	compression, err := newRequestCompression("", 0, false)
+	assert.NoError(t, err)
+	assert.Nil(t, compression)
+}
@@ -1,22 +1,43 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/Financial-Times/go-logger"
 	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
 )
 
+const (
+	defaultPlainHTTPMethod     = "POST"
+	defaultPlainHTTPPath       = "/notify"
+	defaultPlainHTTPHealthPath = "/__health"
+)
+
 type plainHTTPMessageProducer struct {
-	config queueProducer.MessageProducerConfig
-	client plainHttpClient
+	config        queueProducer.MessageProducerConfig
+	client        plainHttpClient
+	headerMapping *headerMapping
+	method        string
+	path          *urlTemplate
+	healthPath    string
+	compression   *requestCompression
+}
+
+// plainHTTPConfig holds the settings specific to the plainHTTP producer.
+// Zero values fall back to the cms-notifier defaults.
+type plainHTTPConfig struct {
+	headerMapping *headerMapping
+	method        string
+	path          *urlTemplate
+	healthPath    string
+	compression   *requestCompression
 }
 
 type plainHttpClient interface {
@@ -24,76 +45,113 @@ type plainHttpClient interface {
 }
 
 // newPlainHTTPMessageProducer returns a plain-http-producer which behaves as a producer for kafka (writes messages to kafka), but it's actually making a simple http call to an endpoint
-func newPlainHTTPMessageProducer(config queueProducer.MessageProducerConfig) queueProducer.MessageProducer {
-	cmsNotifier := &plainHTTPMessageProducer{config, &http.Client{
-		Timeout: 60 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 100,
-			Dial: (&net.Dialer{
-				KeepAlive: 30 * time.Second,
-			}).Dial,
-		}}}
+func newPlainHTTPMessageProducer(config queueProducer.MessageProducerConfig, plainHTTPConf plainHTTPConfig) queueProducer.MessageProducer {
+	cmsNotifier := &plainHTTPMessageProducer{
+		config: config,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 100,
+				Dial: (&net.Dialer{
+					KeepAlive: 30 * time.Second,
+				}).Dial,
+			}},
+		headerMapping: plainHTTPConf.headerMapping,
+		method:        plainHTTPConf.method,
+		path:          plainHTTPConf.path,
+		healthPath:    plainHTTPConf.healthPath,
+		compression:   plainHTTPConf.compression.forDestination(),
+	}
 	return cmsNotifier
 }
 
 func (c *plainHTTPMessageProducer) SendMessage(uuid string, message queueProducer.Message) (err error) {
-	req, err := http.NewRequest("POST", c.config.Addr+"/notify", strings.NewReader(message.Body))
-	if err != nil {
-		errMsg := fmt.Sprintf("Error creating new request: %v", err.Error())
-		return errors.New(errMsg)
+	method := c.requestMethod()
+	path := defaultPlainHTTPPath
+	if c.path != nil {
+		path, err = c.path.expand(message.Headers, message.Body)
+		if err != nil {
+			return fmt.Errorf("Error building request path from template %s: %v", c.path, err)
+		}
 	}
 
-	req.Header.Add("X-Request-Id", message.Headers["X-Request-Id"])
+	if _, found := messageHeaders(message.Headers).Lookup("Origin-System-Id"); !found {
+		logger.NewEntry(messageHeaders(message.Headers).Get("X-Request-Id")).WithUUID(uuid).Info("Couldn't extract origin system id. Going on.")
+	}
 
-	originSystem, found := message.Headers["Origin-System-Id"]
-	if !found {
-		logger.NewEntry(message.Headers["X-Request-Id"]).WithUUID(uuid).Info("Couldn't extract origin system id. Going on.")
-	} else {
-		req.Header.Add("X-Origin-System-Id", originSystem)
+	url := c.config.Addr + path
+	encoding := c.compression.contentEncoding(c.client, url, c.config.Authorization, len(message.Body))
+	status, err := c.send(method, url, message, encoding)
+	if err == nil && status == http.StatusUnsupportedMediaType && encoding != "" {
+		logger.NewEntry(messageHeaders(message.Headers).Get("X-Request-Id")).WithUUID(uuid).Warn("Destination rejected " + encoding + " request body, sending it uncompressed")
+		c.compression.unsupported()
+		status, err = c.send(method, url, message, "")
+	}
+	if err != nil {
+		return err
 	}
 
-	schemaVersion, found := message.Headers["X-Schema-Version"]
-	if found {
-		req.Header.Add("X-Schema-Version", schemaVersion)
+	if status != http.StatusOK {
+		errMsg := fmt.Sprintf("Forwarding message with tid: %s is not successful. Status: %d", messageHeaders(message.Headers).Get("X-Request-Id"), status)
+		return errors.New(errMsg)
 	}
+	return nil
+}
 
-	timestamp := message.Headers["Message-Timestamp"]
-	if timestamp != "" {
-		req.Header.Add("Message-Timestamp", timestamp)
+// send executes the request, compressing the body with the given content encoding, and returns the response status
+func (c *plainHTTPMessageProducer) send(method string, url string, message queueProducer.Message, encoding string) (int, error) {
+	body, err := compressBody(encoding, message.Body)
+	if err != nil {
+		return 0, fmt.Errorf("Error compressing request body: %v", err)
 	}
-	if len(c.config.Authorization) > 0 {
-		req.Header.Add("Authorization", c.config.Authorization)
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		errMsg := fmt.Sprintf("Error creating new request: %v", err.Error())
+		return 0, errors.New(errMsg)
 	}
 
-	nativeHash, found := message.Headers["Native-Hash"]
-	if found {
-		req.Header.Add("X-Native-Hash", nativeHash)
+	mapping := c.headerMapping
+	if mapping == nil {
+		mapping = &defaultHeaderMapping
+	}
+	for name, value := range mapping.apply(message.Headers) {
+		req.Header.Add(name, value)
 	}
 
-	contentType, found := message.Headers["Content-Type"]
-	if found {
-		req.Header.Add("Content-Type", contentType)
+	if len(c.config.Authorization) > 0 {
+		req.Header.Set("Authorization", c.config.Authorization)
+	}
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
 	}
 
 	resp, err := c.client.Do(req)
 	if err != nil {
-		errMsg := fmt.Sprintf("Error executing POST request to the ELB: %v", err.Error())
-		return errors.New(errMsg)
+		errMsg := fmt.Sprintf("Error executing %s request to the ELB: %v", method, err.Error())
+		return 0, errors.New(errMsg)
 	}
 	defer func() {
 		io.Copy(ioutil.Discard, resp.Body)
 		resp.Body.Close()
 	}()
+	return resp.StatusCode, nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("Forwarding message with tid: %s is not successful. Status: %d", message.Headers["X-Request-Id"], resp.StatusCode)
-		return errors.New(errMsg)
+func (c *plainHTTPMessageProducer) requestMethod() string {
+	if c.method == "" {
+		return defaultPlainHTTPMethod
 	}
-	return nil
+	return c.method
 }
 
 func (c *plainHTTPMessageProducer) ConnectivityCheck() (string, error) {
-	req, err := http.NewRequest("GET", c.config.Addr+"/__health", nil)
+	healthPath := c.healthPath
+	if healthPath == "" {
+		healthPath = defaultPlainHTTPHealthPath
+	}
+
+	req, err := http.NewRequest("GET", c.config.Addr+healthPath, nil)
 	if err != nil {
 		return "Forwarding messages is broken. Error creating new plainHttp producer healthcheck request", err
 	}
@@ -110,7 +168,7 @@ func (c *plainHTTPMessageProducer) ConnectivityCheck() (string, error) {
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		errMsg := fmt.Sprintf("Healthcheck: Request to plainHTTP producer /__health endpoint failed. Status: %d.", resp.StatusCode)
+		errMsg := fmt.Sprintf("Healthcheck: Request to plainHTTP producer %s endpoint failed. Status: %d.", healthPath, resp.StatusCode)
 		return "Forwarding messages is broken.", errors.New(errMsg)
 	}
 
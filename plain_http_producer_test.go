@@ -238,8 +238,8 @@ func TestSendMessage(t *testing.T) {
 
 	for _, test := range tests {
 		cmsNotifierTest := &plainHTTPMessageProducer{
-			test.config,
-			&dummyHttpClient{
+			config: test.config,
+			client: &dummyHttpClient{
 				assert:  assert.New(t),
 				address: test.config.Addr,
 				headers: test.expectedHeaders,
@@ -279,3 +279,43 @@ func (d *dummyHttpClient) Do(req *http.Request) (resp *http.Response, err error)
 
 	return &d.resp, nil
 }
+
+type recordingHttpClient struct {
+	requests []*http.Request
+}
+
+func (r *recordingHttpClient) Do(req *http.Request) (resp *http.Response, err error) {
+	r.requests = append(r.requests, req)
+	return &http.Response{StatusCode: http.StatusOK, Body: ioutil.NopCloser(bytes.NewBuffer([]byte{}))}, nil
+}
+
+func TestSendMessageTemplatedPath(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	path, err := parseURLTemplate("/content/{uuid}")
+	assert.NoError(t, err)
+
+	client := &recordingHttpClient{}
+	p := &plainHTTPMessageProducer{
+		config:     queueProducer.MessageProducerConfig{Addr: "http://address"},
+		client:     client,
+		method:     "PUT",
+		path:       path,
+		healthPath: "/__gtg",
+	}
+
+	err = p.SendMessage("", queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_test"},
+		Body:    `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+	})
+	assert.NoError(t, err)
+	err = p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{}`})
+	assert.Error(t, err, "Messages without the templated field can't be sent")
+
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	assert.Len(t, client.requests, 2)
+	assert.Equal(t, "PUT", client.requests[0].Method)
+	assert.Equal(t, "http://address/content/7543220a-2389-11e5-bd83-71cb60e8f08c", client.requests[0].URL.String())
+	assert.Equal(t, "http://address/__gtg", client.requests[1].URL.String())
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+)
+
+const defaultLaneName = "default"
+
+// priorityClass assigns the messages whose header matches one of the values to a dedicated lane
+type priorityClass struct {
+	Name   string   `json:"name"`
+	Header string   `json:"header"`
+	Values []string `json:"values"`
+}
+
+// priorityLanes splits consumed batches into one queue per priority class. The classes are ordered
+// from the highest to the lowest priority, messages not matching any class go to the default lane, which is drained last.
+// A nil priorityLanes keeps every message in a single lane, in consumption order.
+type priorityLanes struct {
+	classes []priorityClass
+}
+
+// parsePriorityClasses parses a JSON array of priority classes, e.g.
+// [{"name":"takedowns","header":"Message-Type","values":["cms-content-takedown"]}]
+func parsePriorityClasses(spec string) (*priorityLanes, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	var classes []priorityClass
+	if err := json.Unmarshal([]byte(spec), &classes); err != nil {
+		return nil, err
+	}
+	for i, class := range classes {
+		if class.Name == "" || class.Name == defaultLaneName {
+			return nil, fmt.Errorf("priority class %d must have a name other than '%s'", i, defaultLaneName)
+		}
+		if class.Header == "" || len(class.Values) == 0 {
+			return nil, errors.New("priority class " + class.Name + " must define a header and at least one value")
+		}
+	}
+	return &priorityLanes{classes: classes}, nil
+}
+
+// lane returns the index of the lane the message belongs to, the default lane being len(classes)
+func (p *priorityLanes) lane(msg queueConsumer.Message) int {
+	if p == nil {
+		return 0
+	}
+	for i, class := range p.classes {
+		value, found := messageHeaders(msg.Headers).Lookup(class.Header)
+		if !found {
+			continue
+		}
+		for _, v := range class.Values {
+			if v == value {
+				return i
+			}
+		}
+	}
+	return len(p.classes)
+}
+
+func (p *priorityLanes) laneName(lane int) string {
+	if p == nil || lane >= len(p.classes) {
+		return defaultLaneName
+	}
+	return p.classes[lane].Name
+}
+
+// split distributes the messages over the lanes, keeping the consumption order within each lane.
+// The messages about the same content all go to the lane of the highest priority one, so that they keep
+// their relative order: a takedown is never forwarded before an earlier publish of the same content.
+func (p *priorityLanes) split(msgs []queueConsumer.Message) [][]queueConsumer.Message {
+	if p == nil {
+		return [][]queueConsumer.Message{msgs}
+	}
+
+	msgLanes := make([]int, len(msgs))
+	uuids := make([]string, len(msgs))
+	contentLanes := make(map[string]int)
+	for i, msg := range msgs {
+		msgLanes[i] = p.lane(msg)
+		if uuids[i] = contentUUID(msg.Body); uuids[i] == "" {
+			continue
+		}
+		if lane, found := contentLanes[uuids[i]]; !found || msgLanes[i] < lane {
+			contentLanes[uuids[i]] = msgLanes[i]
+		}
+	}
+
+	lanes := make([][]queueConsumer.Message, len(p.classes)+1)
+	for i, msg := range msgs {
+		lane := msgLanes[i]
+		if uuids[i] != "" {
+			lane = contentLanes[uuids[i]]
+		}
+		lanes[lane] = append(lanes[lane], msg)
+	}
+	return lanes
+}
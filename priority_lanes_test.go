@@ -0,0 +1,111 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePriorityClasses(t *testing.T) {
+	var tests = []struct {
+		spec        string
+		expectedErr bool
+	}{
+		{"", false},
+		{`[{"name":"takedowns","header":"Message-Type","values":["cms-content-takedown"]}]`, false},
+		{`[{"name":"takedowns","header":"Message-Type"}]`, true},
+		{`[{"name":"default","header":"Message-Type","values":["cms-content-takedown"]}]`, true},
+		{`[{"header":"Message-Type","values":["cms-content-takedown"]}]`, true},
+		{`{"name":"takedowns"}`, true},
+	}
+
+	for _, test := range tests {
+		_, err := parsePriorityClasses(test.spec)
+		assert.Equal(t, test.expectedErr, err != nil, test.spec)
+	}
+}
+
+func TestPriorityLanesSplit(t *testing.T) {
+	lanes, err := parsePriorityClasses(`[
+		{"name":"takedowns","header":"Message-Type","values":["cms-content-takedown"]},
+		{"name":"methode","header":"Origin-System-Id","values":["http://cmdb.ft.com/systems/methode-web-pub"]}
+	]`)
+	assert.NoError(t, err)
+
+	msgs := []queueConsumer.Message{
+		{Headers: map[string]string{"X-Request-Id": "tid_1", "Message-Type": "cms-content-published"}},
+		{Headers: map[string]string{"X-Request-Id": "tid_2", "Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub"}},
+		{Headers: map[string]string{"X-Request-Id": "tid_3", "Message-Type": "cms-content-takedown"}},
+		{Headers: map[string]string{"X-Request-Id": "tid_4", "Message-Type": "cms-content-takedown", "Origin-System-Id": "http://cmdb.ft.com/systems/methode-web-pub"}},
+		{Headers: map[string]string{"X-Request-Id": "tid_5"}},
+	}
+
+	var actual [][]string
+	for _, lane := range lanes.split(msgs) {
+		var tids []string
+		for _, msg := range lane {
+			tids = append(tids, msg.Headers["X-Request-Id"])
+		}
+		actual = append(actual, tids)
+	}
+	assert.Equal(t, [][]string{{"tid_3", "tid_4"}, {"tid_2"}, {"tid_1", "tid_5"}}, actual)
+	assert.Equal(t, "takedowns", lanes.laneName(0))
+	assert.Equal(t, defaultLaneName, lanes.laneName(2))
+}
+
+func TestNilPriorityLanesKeepOrder(t *testing.T) {
+	var lanes *priorityLanes
+	msgs := []queueConsumer.Message{
+		{Headers: map[string]string{"X-Request-Id": "tid_1"}},
+		{Headers: map[string]string{"X-Request-Id": "tid_2"}},
+	}
+	assert.Equal(t, [][]queueConsumer.Message{msgs}, lanes.split(msgs))
+}
+
+func TestPriorityLanesKeepContentOrder(t *testing.T) {
+	lanes, err := parsePriorityClasses(`[{"name":"takedowns","header":"Message-Type","values":["cms-content-takedown"]}]`)
+	assert.NoError(t, err)
+
+	msgs := []queueConsumer.Message{
+		{Headers: map[string]string{"X-Request-Id": "tid_1"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_2"}, Body: `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_3", "Message-Type": "cms-content-takedown"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_4"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_5"}, Body: "not json"},
+	}
+
+	var actual [][]string
+	for _, lane := range lanes.split(msgs) {
+		var tids []string
+		for _, msg := range lane {
+			tids = append(tids, msg.Headers["X-Request-Id"])
+		}
+		actual = append(actual, tids)
+	}
+	assert.Equal(t, [][]string{{"tid_1", "tid_3", "tid_4"}, {"tid_2", "tid_5"}}, actual, "The messages about the taken down content move to its lane in order")
+}
+
+func TestForwardBatchByPriority(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	lanes, err := parsePriorityClasses(`[{"name":"takedowns","header":"Message-Type","values":["cms-content-takedown"]}]`)
+	assert.NoError(t, err)
+	recorder := &recordingBatchProducer{}
+	bridge := BridgeApp{producerInstance: recorder, priorityLanes: lanes}
+
+	bridge.forwardBatch([]queueConsumer.Message{
+		{Headers: map[string]string{"X-Request-Id": "tid_1"}, Body: `{"uuid":"1"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_2"}, Body: `{"uuid":"2","version":1}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_3", "Message-Type": "cms-content-takedown"}, Body: `{"uuid":"3"}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_4", "Message-Type": "cms-content-takedown"}, Body: `{"uuid":"2","version":2}`},
+		{Headers: map[string]string{"X-Request-Id": "tid_5"}, Body: `{"uuid":"5"}`},
+	})
+
+	var bodies []string
+	for _, batch := range recorder.recorded() {
+		bodies = append(bodies, batch...)
+	}
+	assert.Equal(t, []string{`{"uuid":"2","version":1}`, `{"uuid":"3"}`, `{"uuid":"2","version":2}`, `{"uuid":"1"}`, `{"uuid":"5"}`}, bodies,
+		"Takedowns are forwarded first, after the earlier messages about the same content")
+}
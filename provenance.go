@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// provenanceHeaderNames holds the names of the headers added to forwarded messages
+type provenanceHeaderNames struct {
+	Service   string `json:"service"`
+	Source    string `json:"source"`
+	Topic     string `json:"topic"`
+	Partition string `json:"partition"`
+	Offset    string `json:"offset"`
+	BridgedAt string `json:"bridgedAt"`
+}
+
+var defaultProvenanceHeaderNames = provenanceHeaderNames{
+	Service:   "X-Bridge-Service",
+	Source:    "X-Bridge-Source",
+	Topic:     "X-Bridge-Source-Topic",
+	Partition: "X-Bridge-Source-Partition",
+	Offset:    "X-Bridge-Source-Offset",
+	BridgedAt: "X-Bridged-At",
+}
+
+// sourcePosition locates a consumed message in the source topic, for the sources which expose it.
+// The stream sources have no partitions, only an offset in the stream.
+type sourcePosition struct {
+	partitioned bool
+	partition   int32
+	offset      int64
+}
+
+// provenance stamps forwarded messages with where they were bridged from. A nil provenance adds no headers.
+type provenance struct {
+	serviceName string
+	source      string
+	topic       string
+	headers     provenanceHeaderNames
+}
+
+// parseProvenanceHeaderNames overrides the default header names with the ones provided as JSON,
+// e.g. {"service":"X-Bridged-By"}
+func parseProvenanceHeaderNames(spec string) (provenanceHeaderNames, error) {
+	names := defaultProvenanceHeaderNames
+	if strings.TrimSpace(spec) == "" {
+		return names, nil
+	}
+	err := json.Unmarshal([]byte(spec), &names)
+	return names, err
+}
+
+// provenanceSource describes the source of the messages for the source header: its comma-separated addresses
+// without their credentials, prefixed with the stream for the stream sources, e.g. UPP@nats://nats:4222
+func provenanceSource(addresses string, stream string) string {
+	var stripped []string
+	for _, address := range strings.Split(addresses, ",") {
+		address = strings.TrimSpace(address)
+		if u, err := url.Parse(address); err == nil && u.User != nil {
+			u.User = nil
+			address = u.String()
+		}
+		stripped = append(stripped, address)
+	}
+	source := strings.Join(stripped, ",")
+	if stream != "" {
+		source = stream + "@" + source
+	}
+	return source
+}
+
+func (n provenanceHeaderNames) all() []string {
+	return []string{n.Service, n.Source, n.Topic, n.Partition, n.Offset, n.BridgedAt}
+}
+
+func (p *provenance) stamp(headers map[string]string, position *sourcePosition, bridgedAt time.Time) {
+	if p == nil {
+		return
+	}
+	h := messageHeaders(headers)
+	h.Set(p.headers.Service, p.serviceName)
+	h.Set(p.headers.Source, p.source)
+	h.Set(p.headers.Topic, p.topic)
+	if position != nil {
+		if position.partitioned {
+			h.Set(p.headers.Partition, strconv.FormatInt(int64(position.partition), 10))
+		}
+		h.Set(p.headers.Offset, strconv.FormatInt(position.offset, 10))
+	}
+	h.Set(p.headers.BridgedAt, bridgedAt.UTC().Format(time.RFC3339Nano))
+}
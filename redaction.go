@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/Financial-Times/go-logger"
+)
+
+const (
+	redactBlank = "blank"
+	redactHash  = "hash"
+	redactDrop  = "drop"
+
+	redactedHashPrefix = "sha256:"
+)
+
+// redactionField redacts the value found at a dotted path of the body:
+// blank replaces it with an empty string, hash with the SHA-256 of its value and drop removes the field
+type redactionField struct {
+	Path   string `json:"path"`
+	Action string `json:"action"`
+}
+
+// redactionRules removes sensitive content from messages before they are forwarded, typically
+// by the bridges copying production content into lower environments. A nil redactionRules leaves messages untouched.
+type redactionRules struct {
+	Fields  []redactionField `json:"fields"`
+	Headers []string         `json:"headers"`
+
+	verify bool
+}
+
+// parseRedactionRules parses a JSON object of body fields and header names to redact
+func parseRedactionRules(spec string, verify bool) (*redactionRules, error) {
+	if strings.TrimSpace(spec) == "" {
+		return nil, nil
+	}
+
+	rules := &redactionRules{verify: verify}
+	if err := json.Unmarshal([]byte(spec), rules); err != nil {
+		return nil, err
+	}
+	for i, field := range rules.Fields {
+		if field.Path == "" {
+			return nil, fmt.Errorf("redaction field %d has no path", i+1)
+		}
+		switch field.Action {
+		case redactBlank, redactHash, redactDrop:
+		default:
+			return nil, fmt.Errorf("redaction field %s has an unknown action %q", field.Path, field.Action)
+		}
+	}
+	for i, name := range rules.Headers {
+		rules.Headers[i] = canonicalHeaderName(name)
+	}
+	return rules, nil
+}
+
+// apply strips the headers and redacts the body fields, failing for bodies which aren't JSON
+// rather than forwarding them unredacted. In verification mode the redacted fields are logged.
+func (r *redactionRules) apply(tid string, headers map[string]string, body string) (string, error) {
+	if r == nil {
+		return body, nil
+	}
+
+	var redacted []string
+	for name := range headers {
+		for _, strip := range r.Headers {
+			if canonicalHeaderName(name) == strip {
+				delete(headers, name)
+				redacted = append(redacted, "header:"+name)
+			}
+		}
+	}
+
+	if len(r.Fields) > 0 {
+		doc, err := decodeJSON([]byte(body))
+		if err != nil {
+			return "", errors.New("couldn't redact a body which is not JSON: " + err.Error())
+		}
+		for _, field := range r.Fields {
+			value, found := getJSONPath(doc, field.Path)
+			if !found {
+				continue
+			}
+			switch field.Action {
+			case redactBlank:
+				err = setJSONPath(doc, field.Path, "")
+			case redactHash:
+				err = setJSONPath(doc, field.Path, hashRedactedValue(value))
+			case redactDrop:
+				removeJSONPath(doc, field.Path)
+			}
+			if err != nil {
+				return "", errors.New("couldn't redact " + field.Path + ": " + err.Error())
+			}
+			redacted = append(redacted, field.Action+":"+field.Path)
+		}
+		encoded, err := encodeJSON(doc)
+		if err != nil {
+			return "", err
+		}
+		body = string(encoded)
+	}
+
+	if r.verify {
+		logger.NewEntry(tid).WithField("redacted", strings.Join(redacted, ",")).Infof("Redacted %d fields", len(redacted))
+	}
+	return body, nil
+}
+
+// hashRedactedValue hashes strings as they are and any other value as JSON, so that equal values keep matching
+func hashRedactedValue(value interface{}) string {
+	data, ok := value.(string)
+	if !ok {
+		encoded, _ := encodeJSON(value)
+		data = string(encoded)
+	}
+	sum := sha256.Sum256([]byte(data))
+	return redactedHashPrefix + hex.EncodeToString(sum[:])
+}
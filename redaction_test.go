@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedaction(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	body := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","byline":"Jane Doe","author":{"email":"jane@example.com","id":42},"notes":"internal"}`
+
+	var tests = []struct {
+		name            string
+		spec            string
+		expectedBody    string
+		expectedHeaders map[string]string
+	}{
+		{
+			"blank",
+			`{"fields":[{"path":"byline","action":"blank"}]}`,
+			`{"author":{"email":"jane@example.com","id":42},"byline":"","notes":"internal","uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			map[string]string{"X-Request-Id": "tid_test", "Authorization": "secret"},
+		},
+		{
+			"hash and drop",
+			`{"fields":[{"path":"author.email","action":"hash"},{"path":"author.id","action":"hash"},{"path":"notes","action":"drop"},{"path":"missing","action":"drop"}]}`,
+			`{"author":{"email":"sha256:8c87b489ce35cf2e2f39f80e282cb2e804932a56a213983eeeb428407d43b52d","id":"sha256:73475cb40a568e8da8a045ced110137e159f890ac4da883b6b17dc651b3a8049"},"byline":"Jane Doe","uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+			map[string]string{"X-Request-Id": "tid_test", "Authorization": "secret"},
+		},
+		{
+			"headers only",
+			`{"headers":["authorization"]}`,
+			body,
+			map[string]string{"X-Request-Id": "tid_test"},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := parseRedactionRules(test.spec, true)
+		assert.NoError(t, err, test.name)
+		headers := map[string]string{"X-Request-Id": "tid_test", "Authorization": "secret"}
+		redacted, err := rules.apply("tid_test", headers, body)
+		assert.NoError(t, err, test.name)
+		assert.Equal(t, test.expectedBody, redacted, test.name)
+		assert.Equal(t, test.expectedHeaders, headers, test.name)
+	}
+}
+
+func TestRedactionNonJSONBody(t *testing.T) {
+	rules, err := parseRedactionRules(`{"fields":[{"path":"byline","action":"blank"}]}`, false)
+	assert.NoError(t, err)
+	_, err = rules.apply("tid_test", map[string]string{}, "<xml/>")
+	assert.Error(t, err, "Bodies which can't be redacted must not be forwarded")
+
+	var disabled *redactionRules
+	body, err := disabled.apply("tid_test", map[string]string{}, "<xml/>")
+	assert.NoError(t, err)
+	assert.Equal(t, "<xml/>", body)
+}
+
+func TestParseRedactionRulesInvalid(t *testing.T) {
+	var tests = []string{
+		`{"fields":[{"path":"byline","action":"mask"}]}`,
+		`{"fields":[{"action":"drop"}]}`,
+		`[`,
+	}
+	for _, spec := range tests {
+		_, err := parseRedactionRules(spec, false)
+		assert.Error(t, err, spec)
+	}
+
+	rules, err := parseRedactionRules("", false)
+	assert.NoError(t, err)
+	assert.Nil(t, rules)
+}
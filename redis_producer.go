@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisRequestTimeout = 30 * time.Second
+
+	// the fields of the stream entries: the message headers as a JSON object, and the body
+	redisHeadersField = "headers"
+	redisBodyField    = "body"
+)
+
+// redisProducerConfig holds the settings specific to the redis producer
+type redisProducerConfig struct {
+	stream string
+	maxLen int64
+}
+
+// newRedisClient connects to a redis:// URL, or to a bare host:port
+func newRedisClient(address string) (*redis.Client, error) {
+	if address == "" {
+		return nil, errors.New("the redis address is required")
+	}
+	if !strings.Contains(address, "://") {
+		return redis.NewClient(&redis.Options{Addr: address}), nil
+	}
+	options, err := redis.ParseURL(address)
+	if err != nil {
+		return nil, err
+	}
+	return redis.NewClient(options), nil
+}
+
+// redisStreamProducer appends every message to a Redis Stream as an entry with a headers and a body field.
+// The stream is trimmed to about maxLen entries as it grows, so that it doesn't fill the memory of the server.
+type redisStreamProducer struct {
+	client *redis.Client
+	stream string
+	maxLen int64
+}
+
+func newRedisStreamProducer(address string, config redisProducerConfig) (*redisStreamProducer, error) {
+	if config.stream == "" {
+		return nil, errors.New("the redis stream is required")
+	}
+	if config.maxLen < 0 {
+		return nil, errors.New("the redis stream max length can't be negative")
+	}
+	client, err := newRedisClient(address)
+	if err != nil {
+		return nil, err
+	}
+	return &redisStreamProducer{client: client, stream: config.stream, maxLen: config.maxLen}, nil
+}
+
+func (p *redisStreamProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	args, err := p.xAddArgs(message)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), redisRequestTimeout)
+		defer cancel()
+		err = p.client.XAdd(ctx, args).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(message.Headers).Get("X-Request-Id"), err)
+	}
+	return nil
+}
+
+// SendBatch appends the messages in a single pipeline, each entry succeeding or failing on its own
+func (p *redisStreamProducer) SendBatch(messages []queueProducer.Message) []error {
+	errs := make([]error, len(messages))
+	commands := make([]*redis.StringCmd, len(messages))
+	ctx, cancel := context.WithTimeout(context.Background(), redisRequestTimeout)
+	defer cancel()
+	pipeline := p.client.Pipeline()
+	for i, message := range messages {
+		var args *redis.XAddArgs
+		if args, errs[i] = p.xAddArgs(message); errs[i] == nil {
+			commands[i] = pipeline.XAdd(ctx, args)
+		}
+	}
+	// the pipeline error is the first failed command's, the outcome of each one is read from its command
+	pipeline.Exec(ctx)
+
+	for i, command := range commands {
+		if command != nil {
+			errs[i] = command.Err()
+		}
+		if errs[i] != nil {
+			errs[i] = fmt.Errorf("Forwarding message with tid: %s is not successful: %v", messageHeaders(messages[i].Headers).Get("X-Request-Id"), errs[i])
+		}
+	}
+	return errs
+}
+
+func (p *redisStreamProducer) xAddArgs(message queueProducer.Message) (*redis.XAddArgs, error) {
+	values, err := newRedisEntryValues(message)
+	if err != nil {
+		return nil, err
+	}
+	return &redis.XAddArgs{Stream: p.stream, MaxLen: p.maxLen, Approx: p.maxLen > 0, Values: values}, nil
+}
+
+// ConnectivityCheck pings the server and checks the stream key, if it exists, holds a stream
+func (p *redisStreamProducer) ConnectivityCheck() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisRequestTimeout)
+	defer cancel()
+	keyType, err := p.client.Type(ctx, p.stream).Result()
+	if err != nil {
+		return "Forwarding messages is broken. Couldn't reach the redis server", err
+	}
+	if keyType != "stream" && keyType != "none" {
+		return "Forwarding messages is broken. The redis key " + p.stream + " isn't a stream", errors.New("the key holds a " + keyType)
+	}
+	return "Connectivity to redis is OK", nil
+}
+
+func (p *redisStreamProducer) Close() error {
+	return p.client.Close()
+}
+
+func newRedisEntryValues(message queueProducer.Message) ([]string, error) {
+	headers, err := json.Marshal(message.Headers)
+	if err != nil {
+		return nil, err
+	}
+	return []string{redisHeadersField, string(headers), redisBodyField, message.Body}, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// redisTestAddress returns REDIS_TEST_URL when set, to run against a local redis-server, or an in-memory server
+func redisTestAddress(t *testing.T) string {
+	if url := os.Getenv("REDIS_TEST_URL"); url != "" {
+		return url
+	}
+	return miniredis.RunT(t).Addr()
+}
+
+func TestRedisStreamRoundTrip(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	address := redisTestAddress(t)
+	stream := "kafka-bridge-test-" + time.Now().UTC().Format("20060102T150405.000000000")
+
+	forwarded := make(chan queueConsumer.Message, 3)
+	source, err := newRedisStreamSource(address, redisSourceConfig{group: "kafka-bridge", consumer: "test", stream: stream, offset: "smallest"},
+		func(msg queueConsumer.Message, position *sourcePosition) { forwarded <- msg })
+	assert.NoError(t, err)
+	defer source.client.Del(context.Background(), stream)
+	_, err = source.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	p, err := newRedisStreamProducer(address, redisProducerConfig{stream: stream, maxLen: 1000})
+	assert.NoError(t, err)
+	defer p.Close()
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+
+	message := queueProducer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_first", "Message-Id": "fc429b46-2500-4fe7-88bb-fd507fbaf00c"},
+		Body:    `{"uuid":"c94a3a57-3c99-423c-a6bd-ed8c4c10a3c3"}`,
+	}
+	assert.NoError(t, p.SendMessage("", message))
+	second := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_second"}, Body: `{}`}
+	assert.Equal(t, []error{nil}, p.SendBatch([]queueProducer.Message{second}))
+
+	consumer := source.consumer()
+	go consumer.Start()
+	defer consumer.Stop()
+	for _, expected := range []queueProducer.Message{message, second} {
+		select {
+		case msg := <-forwarded:
+			assert.Equal(t, queueConsumer.Message{Headers: expected.Headers, Body: expected.Body}, msg)
+		case <-time.After(10 * time.Second):
+			t.Fatal("The message wasn't consumed")
+		}
+	}
+	assert.Eventually(t, func() bool {
+		pending, err := source.client.XPending(context.Background(), stream, "kafka-bridge").Result()
+		return err == nil && pending.Count == 0
+	}, 5*time.Second, 50*time.Millisecond, "The forwarded entries are acknowledged")
+}
+
+func TestRedisSourceForwardsPendingEntries(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	address := redisTestAddress(t)
+	stream := "kafka-bridge-test-" + time.Now().UTC().Format("20060102T150405.000000000")
+	config := redisSourceConfig{group: "kafka-bridge", consumer: "test", stream: stream}
+
+	source, err := newRedisStreamSource(address, config, func(queueConsumer.Message, *sourcePosition) {})
+	assert.NoError(t, err)
+	defer source.client.Del(context.Background(), stream)
+	p, err := newRedisStreamProducer(address, redisProducerConfig{stream: stream})
+	assert.NoError(t, err)
+	defer p.Close()
+	assert.NoError(t, p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_pending"}, Body: `{}`}))
+
+	// read without acknowledging, as a bridge stopped before forwarding the entry
+	ctx := context.Background()
+	_, err = source.client.XReadGroup(ctx, &redis.XReadGroupArgs{Group: "kafka-bridge", Consumer: "test", Streams: []string{stream, ">"}, Count: 1, Block: -1}).Result()
+	assert.NoError(t, err)
+
+	forwarded := make(chan queueConsumer.Message, 1)
+	restarted, err := newRedisStreamSource(address, config, func(msg queueConsumer.Message, position *sourcePosition) { forwarded <- msg })
+	assert.NoError(t, err, "The existing group is joined")
+	consumer := restarted.consumer()
+	go consumer.Start()
+	defer consumer.Stop()
+	select {
+	case msg := <-forwarded:
+		assert.Equal(t, "tid_pending", msg.Headers["X-Request-Id"])
+	case <-time.After(10 * time.Second):
+		t.Fatal("The pending entry wasn't forwarded")
+	}
+}
+
+func TestRedisProducerTrimsStream(t *testing.T) {
+	server := miniredis.RunT(t)
+	p, err := newRedisStreamProducer("redis://"+server.Addr()+"/0", redisProducerConfig{stream: "upp", maxLen: 2})
+	assert.NoError(t, err)
+	defer p.Close()
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, p.SendMessage("", queueProducer.Message{Headers: map[string]string{}, Body: `{}`}))
+	}
+	length, err := p.client.XLen(context.Background(), "upp").Result()
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, length, int64(2))
+
+	server.Set("plain", "value")
+	wrongType, err := newRedisStreamProducer(server.Addr(), redisProducerConfig{stream: "plain"})
+	assert.NoError(t, err)
+	_, err = wrongType.ConnectivityCheck()
+	assert.Error(t, err)
+}
+
+func TestParseRedisEntry(t *testing.T) {
+	var tests = []struct {
+		name          string
+		values        map[string]interface{}
+		expected      queueConsumer.Message
+		expectedError bool
+	}{
+		{"complete", map[string]interface{}{"headers": `{"X-Request-Id":"tid_test"}`, "body": "{}"}, queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: "{}"}, false},
+		{"no headers", map[string]interface{}{"body": "{}"}, queueConsumer.Message{Headers: map[string]string{}, Body: "{}"}, false},
+		{"null headers", map[string]interface{}{"headers": "null", "body": "{}"}, queueConsumer.Message{Headers: map[string]string{}, Body: "{}"}, false},
+		{"trimmed", nil, queueConsumer.Message{}, true},
+		{"no body", map[string]interface{}{"headers": "{}"}, queueConsumer.Message{}, true},
+		{"invalid headers", map[string]interface{}{"headers": "[]", "body": "{}"}, queueConsumer.Message{}, true},
+	}
+	for _, test := range tests {
+		msg, err := parseRedisEntry(test.values)
+		assert.Equal(t, test.expectedError, err != nil, test.name)
+		assert.Equal(t, test.expected, msg, test.name)
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	redisSourceBlock         = 5 * time.Second
+	redisSourceCount         = 100
+	redisSourceRetryInterval = 5 * time.Second
+)
+
+// redisSourceConfig holds the settings of the Redis Streams source
+type redisSourceConfig struct {
+	group    string
+	consumer string
+	stream   string
+	offset   string
+}
+
+// redisStreamSource reads a Redis Stream as a member of a consumer group, so that several bridge instances
+// share the entries. Each entry is acknowledged once it has been forwarded, and the entries a consumer was
+// given but didn't acknowledge before a restart are forwarded again first.
+type redisStreamSource struct {
+	client  *redis.Client
+	stream  string
+	group   string
+	name    string
+	forward func(msg queueConsumer.Message, position *sourcePosition)
+}
+
+func newRedisStreamSource(address string, config redisSourceConfig, forward func(queueConsumer.Message, *sourcePosition)) (*redisStreamSource, error) {
+	if config.group == "" || config.stream == "" {
+		return nil, errors.New("the redis source requires a consumer group and a stream")
+	}
+	var start string
+	switch config.offset {
+	case "", "largest", "latest", "newest":
+		start = "$"
+	case "smallest", "earliest", "oldest":
+		start = "0"
+	default:
+		return nil, errors.New("unknown consumer offset " + config.offset)
+	}
+	name := config.consumer
+	if name == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't name the redis consumer after the hostname: %v", err)
+		}
+		name = hostname
+	}
+
+	client, err := newRedisClient(address)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisRequestTimeout)
+	defer cancel()
+	// the start of an existing group isn't changed, it only applies when the group is created
+	err = client.XGroupCreateMkStream(ctx, config.stream, config.group, start).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		client.Close()
+		return nil, err
+	}
+	return &redisStreamSource{client: client, stream: config.stream, group: config.group, name: name, forward: forward}, nil
+}
+
+// consumer returns a member of the consumer group which consumes until it is stopped
+func (s *redisStreamSource) consumer() queueConsumer.MessageConsumer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &redisStreamConsumer{source: s, ctx: ctx, cancel: cancel}
+}
+
+// ConnectivityCheck fails when the server is unreachable or the consumer group was deleted
+func (s *redisStreamSource) ConnectivityCheck() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisRequestTimeout)
+	defer cancel()
+	groups, err := s.client.XInfoGroups(ctx, s.stream).Result()
+	if err != nil {
+		return "Consuming messages is broken. Couldn't get the consumer groups of redis stream " + s.stream, err
+	}
+	for _, group := range groups {
+		if group.Name == s.group {
+			return "Connectivity to redis is OK", nil
+		}
+	}
+	return "Consuming messages is broken. The consumer group " + s.group + " of redis stream " + s.stream + " doesn't exist", errors.New("consumer group not found")
+}
+
+// consume reads the pending entries of the consumer first, then the new ones. Stopping the consumer
+// cancels a blocking read, and the entries being forwarded are forwarded and acknowledged first.
+func (s *redisStreamSource) consume(ctx context.Context) {
+	from := "0"
+	for ctx.Err() == nil {
+		streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: s.name,
+			Streams:  []string{s.stream, from},
+			Count:    redisSourceCount,
+			Block:    redisSourceBlock,
+		}).Result()
+		if errors.Is(err, redis.Nil) || ctx.Err() != nil {
+			continue
+		}
+		if err != nil {
+			logger.Errorf(map[string]interface{}{"stream": s.stream, "group": s.group}, err, "Reading from the redis stream failed, retrying")
+			select {
+			case <-ctx.Done():
+			case <-time.After(redisSourceRetryInterval):
+			}
+			continue
+		}
+
+		var entries []redis.XMessage
+		if len(streams) > 0 {
+			entries = streams[0].Messages
+		}
+		if from != ">" {
+			if len(entries) == 0 {
+				from = ">"
+				continue
+			}
+			from = entries[len(entries)-1].ID
+		}
+		s.forwardAll(entries)
+	}
+}
+
+func (s *redisStreamSource) forwardAll(entries []redis.XMessage) {
+	for _, entry := range entries {
+		fields := map[string]interface{}{"stream": s.stream, "group": s.group, "id": entry.ID}
+		msg, err := parseRedisEntry(entry.Values)
+		if err != nil {
+			logger.Errorf(fields, err, "Skipping the redis stream entry")
+		} else {
+			s.forward(msg, nil)
+		}
+		if err := s.client.XAck(context.Background(), s.stream, s.group, entry.ID).Err(); err != nil {
+			logger.Errorf(fields, err, "Couldn't acknowledge the redis stream entry")
+		}
+	}
+}
+
+// redisStreamConsumer is a single run of the consumer group member, from Start until Stop
+type redisStreamConsumer struct {
+	source *redisStreamSource
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (c *redisStreamConsumer) Start() {
+	c.source.consume(c.ctx)
+}
+
+func (c *redisStreamConsumer) Stop() {
+	c.cancel()
+}
+
+func (c *redisStreamConsumer) ConnectivityCheck() (string, error) {
+	return c.source.ConnectivityCheck()
+}
+
+// parseRedisEntry reads the message from the fields written by the redis producer. The entries trimmed from
+// the stream while they were pending have no fields.
+func parseRedisEntry(values map[string]interface{}) (queueConsumer.Message, error) {
+	if len(values) == 0 {
+		return queueConsumer.Message{}, errors.New("the entry was trimmed from the stream")
+	}
+	body, ok := values[redisBodyField].(string)
+	if !ok {
+		return queueConsumer.Message{}, errors.New("the entry has no body field")
+	}
+	msg := queueConsumer.Message{Headers: make(map[string]string), Body: body}
+	if headers, ok := values[redisHeadersField].(string); ok {
+		if err := json.Unmarshal([]byte(headers), &msg.Headers); err != nil {
+			return queueConsumer.Message{}, fmt.Errorf("the headers field isn't a JSON object: %v", err)
+		}
+		// a null headers field leaves no map, the forwarder sets headers on every message
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string)
+		}
+	}
+	return msg, nil
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Config holds the connection settings of an S3-compatible object storage, e.g. AWS S3 or a local MinIO
+type s3Config struct {
+	endpoint        string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	useSSL          bool
+}
+
+// newS3Client connects with static credentials when they are provided, or with the AWS credential chain
+// (environment, IAM role) otherwise
+func newS3Client(config s3Config) (*minio.Client, error) {
+	if config.endpoint == "" {
+		return nil, errors.New("the S3 endpoint is required")
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.EnvAWS{},
+		&credentials.IAM{},
+	})
+	if config.accessKeyID != "" {
+		creds = credentials.NewStaticV4(config.accessKeyID, config.secretAccessKey, "")
+	}
+
+	endpoint := strings.TrimPrefix(strings.TrimPrefix(config.endpoint, "https://"), "http://")
+	return minio.New(endpoint, &minio.Options{
+		Creds:  creds,
+		Secure: config.useSSL,
+		Region: config.region,
+	})
+}
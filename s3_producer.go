@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/dchest/uniuri"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	s3ObjectTimeLayout = "20060102T150405.000000000Z"
+	// the healthcheck overwrites a single object under the topic, at most once per s3HealthcheckInterval
+	s3HealthcheckObject   = ".healthcheck"
+	s3HealthcheckInterval = time.Minute
+)
+
+// s3MessageProducer archives the messages in an S3-compatible bucket, under <topic>/<date>/<hour>/ by the time
+// they are written. A single message is written as a JSON object, a batch as one NDJSON object; the records
+// are the ones written by the file producer.
+type s3MessageProducer struct {
+	client *minio.Client
+	bucket string
+	topic  string
+	now    func() time.Time
+
+	// writableAt is the time of the last successful healthcheck write, guarded by the mutex
+	healthcheck sync.Mutex
+	writableAt  time.Time
+}
+
+func newS3MessageProducer(bucket string, topic string, config s3Config) (*s3MessageProducer, error) {
+	if bucket == "" {
+		return nil, errors.New("the S3 bucket is required")
+	}
+	client, err := newS3Client(config)
+	if err != nil {
+		return nil, err
+	}
+	return &s3MessageProducer{client: client, bucket: bucket, topic: topic, now: time.Now}, nil
+}
+
+func (p *s3MessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	now := p.now()
+	tid := messageHeaders(message.Headers).Get("X-Request-Id")
+	data, err := json.Marshal(newFileRecord(message, now))
+	if err != nil {
+		return err
+	}
+	if err := p.put(p.objectKey(now, tid, ".json"), data, "application/json"); err != nil {
+		return fmt.Errorf("Writing message with tid: %s to S3 is not successful: %v", tid, err)
+	}
+	return nil
+}
+
+// SendBatch writes the messages as a single NDJSON object, so they all succeed or fail together
+func (p *s3MessageProducer) SendBatch(messages []queueProducer.Message) []error {
+	now := p.now()
+	errs := make([]error, len(messages))
+	fail := func(err error) []error {
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	for _, message := range messages {
+		if err := encoder.Encode(newFileRecord(message, now)); err != nil {
+			return fail(err)
+		}
+	}
+	if err := p.put(p.objectKey(now, uniuri.NewLen(8), fileSinkExtension), data.Bytes(), "application/x-ndjson"); err != nil {
+		return fail(fmt.Errorf("Writing a batch of %d messages to S3 is not successful: %v", len(messages), err))
+	}
+	return errs
+}
+
+// ConnectivityCheck writes the healthcheck object under the topic, which fails without write access to the bucket.
+// The object is overwritten rather than created and removed, and a successful write is trusted for
+// s3HealthcheckInterval, so that frequent health probes don't add requests and objects to the bucket.
+func (p *s3MessageProducer) ConnectivityCheck() (string, error) {
+	p.healthcheck.Lock()
+	defer p.healthcheck.Unlock()
+	now := p.now()
+	if !p.writableAt.IsZero() && now.Sub(p.writableAt) < s3HealthcheckInterval {
+		return "The S3 bucket is writable", nil
+	}
+	if err := p.put(path.Join(p.topic, s3HealthcheckObject), []byte(formatMessageTimestamp(now)), "text/plain"); err != nil {
+		p.writableAt = time.Time{}
+		return "Forwarding messages is broken. Couldn't write to bucket " + p.bucket, err
+	}
+	p.writableAt = now
+	return "The S3 bucket is writable", nil
+}
+
+func (p *s3MessageProducer) put(key string, data []byte, contentType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), blobStoreTimeout)
+	defer cancel()
+	_, err := p.client.PutObject(ctx, p.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+	return err
+}
+
+// objectKey lays the objects out by topic, date and hour, e.g. NativeCmsPublicationEvents/2026-10-19/01/20261019T010203.000000000Z-tid_test.json
+func (p *s3MessageProducer) objectKey(now time.Time, name string, extension string) string {
+	now = now.UTC()
+	name = strings.NewReplacer("/", "_", " ", "_").Replace(name)
+	return path.Join(p.topic, now.Format("2006-01-02"), now.Format("15"), now.Format(s3ObjectTimeLayout)+"-"+name+extension)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeS3 keeps the objects put in a bucket, rejecting every request when readOnly is set
+type fakeS3 struct {
+	sync.Mutex
+	objects  map[string][]byte
+	puts     int
+	deleted  []string
+	readOnly bool
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.Lock()
+	defer s.Unlock()
+	if s.readOnly {
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+		return
+	}
+	switch r.Method {
+	case "PUT":
+		s.puts++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("X-Amz-Decoded-Content-Length") != "" {
+			body = decodeAWSChunked(body)
+		}
+		s.objects[r.URL.Path] = body
+		w.Header().Set("ETag", `"etag"`)
+	case "GET":
+		body, found := s.objects[r.URL.Path]
+		if !found {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`))
+			return
+		}
+		w.Header().Set("Last-Modified", "Mon, 19 Oct 2026 01:02:03 GMT")
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.Write(body)
+	case "DELETE":
+		s.deleted = append(s.deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// decodeAWSChunked reads the payload of a streaming signed upload, made of <hex size>;chunk-signature=<signature> chunks
+func decodeAWSChunked(body []byte) []byte {
+	var payload []byte
+	reader := bufio.NewReader(bytes.NewReader(body))
+	for {
+		header, err := reader.ReadString('\n')
+		if err != nil {
+			return payload
+		}
+		size, err := strconv.ParseInt(strings.SplitN(strings.TrimSpace(header), ";", 2)[0], 16, 64)
+		if err != nil || size == 0 {
+			return payload
+		}
+		chunk := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, chunk); err != nil {
+			return payload
+		}
+		payload = append(payload, chunk[:size]...)
+	}
+}
+
+func newTestS3Producer(t *testing.T, s3 *fakeS3) *s3MessageProducer {
+	server := httptest.NewServer(s3)
+	t.Cleanup(server.Close)
+	p, err := newS3MessageProducer("archive", "NativeCmsPublicationEvents", s3Config{endpoint: server.URL, region: "eu-west-1", accessKeyID: "key", secretAccessKey: "secret"})
+	assert.NoError(t, err)
+	p.now = func() time.Time { return time.Date(2026, 10, 19, 1, 2, 3, 0, time.UTC) }
+	return p
+}
+
+func TestS3ProducerSendMessage(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	p := newTestS3Producer(t, s3)
+
+	msg := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`}
+	assert.NoError(t, p.SendMessage("", msg))
+
+	data, found := s3.objects["/archive/NativeCmsPublicationEvents/2026-10-19/01/20261019T010203.000000000Z-tid_test.json"]
+	assert.True(t, found, "The object is laid out by topic, date and hour")
+	record := fileRecord{}
+	assert.NoError(t, json.Unmarshal(data, &record))
+	assert.Equal(t, newFileRecord(msg, p.now()), record)
+}
+
+func TestS3ProducerSendBatch(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	p := newTestS3Producer(t, s3)
+
+	errs := p.SendBatch(restTestMessages)
+	assert.Equal(t, []error{nil, nil}, errs)
+	assert.Len(t, s3.objects, 1)
+	for key, data := range s3.objects {
+		assert.True(t, strings.HasPrefix(key, "/archive/NativeCmsPublicationEvents/2026-10-19/01/20261019T010203.000000000Z-"), key)
+		assert.True(t, strings.HasSuffix(key, ".ndjson"), key)
+		var tids []string
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		for scanner.Scan() {
+			record := fileRecord{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+			tids = append(tids, record.TransactionID)
+		}
+		assert.Equal(t, []string{"tid_first", "tid_second"}, tids)
+	}
+
+	s3.readOnly = true
+	for _, err := range p.SendBatch(restTestMessages) {
+		assert.Error(t, err, "The whole batch fails with its object")
+	}
+}
+
+func TestS3ProducerConnectivityCheck(t *testing.T) {
+	s3 := &fakeS3{objects: make(map[string][]byte)}
+	p := newTestS3Producer(t, s3)
+	now := time.Date(2026, 10, 19, 1, 2, 3, 0, time.UTC)
+	p.now = func() time.Time { return now }
+
+	_, err := p.ConnectivityCheck()
+	assert.NoError(t, err)
+	assert.Contains(t, s3.objects, "/archive/NativeCmsPublicationEvents/.healthcheck")
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, s3.puts, "A successful write is trusted for the healthcheck interval")
+	assert.Empty(t, s3.deleted, "The healthcheck object is overwritten, not removed")
+
+	now = now.Add(s3HealthcheckInterval)
+	_, err = p.ConnectivityCheck()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, s3.puts)
+	assert.Len(t, s3.objects, 1, "The same object is overwritten")
+
+	s3.readOnly = true
+	now = now.Add(s3HealthcheckInterval)
+	_, err = p.ConnectivityCheck()
+	assert.Error(t, err, "A read-only credential fails the check")
+	_, err = p.ConnectivityCheck()
+	assert.Error(t, err, "Failures aren't cached")
+
+	_, err = newS3MessageProducer("", "NativeCmsPublicationEvents", s3Config{endpoint: "localhost:9000"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Financial-Times/go-logger"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	schemaValidationPath = "/__schema-validation"
+	defaultSchemaName    = "default"
+)
+
+// schemaValidator validates message bodies against the JSON schemas found in a local directory.
+// The schema is selected by content type and schema version: a message with "Content-Type: application/json"
+// and "X-Schema-Version: 2" is validated against <dir>/application/json/2.json, or against
+// <dir>/application/json/default.json when it has no schema version.
+// Messages for which no schema exists are forwarded unvalidated. A nil validator accepts every message.
+// The schemas are compiled when the validator is created, so that an invalid schema stops the bridge
+// instead of failing every message it selects.
+type schemaValidator struct {
+	sync.Mutex
+	dir     string
+	schemas map[string]*jsonschema.Schema
+	stats   validationStats
+}
+
+type validationStats struct {
+	Validated        int64            `json:"validated"`
+	Failed           int64            `json:"failed"`
+	Unvalidated      int64            `json:"unvalidated"`
+	FailuresBySchema map[string]int64 `json:"failuresBySchema"`
+}
+
+func newSchemaValidator(dir string) (*schemaValidator, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, errors.New(dir + " is not a directory")
+	}
+	schemas, err := compileSchemas(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &schemaValidator{
+		dir:     dir,
+		schemas: schemas,
+		stats:   validationStats{FailuresBySchema: make(map[string]int64)},
+	}, nil
+}
+
+// compileSchemas compiles the <type>/<subtype>/<version>.json files of the directory, keyed by their relative path
+func compileSchemas(dir string) (map[string]*jsonschema.Schema, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	schemas := make(map[string]*jsonschema.Schema, len(paths))
+	for _, path := range paths {
+		schema, err := jsonschema.Compile(path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't compile JSON schema %s: %v", path, err)
+		}
+		schemaFile, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		schemas[filepath.ToSlash(schemaFile)] = schema
+	}
+	return schemas, nil
+}
+
+// validate returns an error if the body doesn't match the schema selected for the message
+func (v *schemaValidator) validate(headers map[string]string, body string) error {
+	if v == nil {
+		return nil
+	}
+
+	schemaFile, schema, err := v.schemaFor(headers)
+	if err != nil {
+		return err
+	}
+	if schema == nil {
+		v.Lock()
+		v.stats.Unvalidated++
+		v.Unlock()
+		return nil
+	}
+
+	doc, err := decodeJSON([]byte(body))
+	if err == nil {
+		err = schema.Validate(doc)
+	}
+
+	v.Lock()
+	defer v.Unlock()
+	v.stats.Validated++
+	if err != nil {
+		v.stats.Failed++
+		v.stats.FailuresBySchema[schemaFile]++
+		return fmt.Errorf("body doesn't match schema %s: %v", schemaFile, err)
+	}
+	return nil
+}
+
+// schemaFor returns the compiled schema selected by the message headers, or nil if there is none.
+// The schemas are never modified after creation, so they are read without locking.
+func (v *schemaValidator) schemaFor(headers map[string]string) (string, *jsonschema.Schema, error) {
+	contentType := messageHeaders(headers).Get("Content-Type")
+	if contentType == "" {
+		return "", nil, nil
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil || strings.Count(mediaType, "/") != 1 || strings.Contains(mediaType, "..") {
+		return "", nil, fmt.Errorf("invalid content type %s", contentType)
+	}
+
+	version := messageHeaders(headers).Get("X-Schema-Version")
+	if version == "" {
+		version = defaultSchemaName
+	}
+	if strings.ContainsAny(version, `/\`) || strings.Contains(version, "..") {
+		return "", nil, fmt.Errorf("invalid schema version %s", version)
+	}
+	schemaFile := filepath.ToSlash(filepath.Join(mediaType, version+".json"))
+
+	return schemaFile, v.schemas[schemaFile], nil
+}
+
+func (v *schemaValidator) statsHandler(w http.ResponseWriter, r *http.Request) {
+	v.Lock()
+	defer v.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v.stats); err != nil {
+		logger.Errorf(nil, err, "Couldn't encode schema validation stats")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+const articleSchema = `{
+	"type": "object",
+	"required": ["uuid", "type"],
+	"properties": {"uuid": {"type": "string"}, "type": {"type": "string"}}
+}`
+
+func newTestSchemaValidator(t *testing.T) *schemaValidator {
+	dir, err := ioutil.TempDir("", "schemas")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "application", "json"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "application", "json", "2.json"), []byte(articleSchema), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "application", "json", "default.json"), []byte(`{"type":"object"}`), 0644))
+
+	v, err := newSchemaValidator(dir)
+	assert.NoError(t, err)
+	return v
+}
+
+func TestSchemaValidatorValidate(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	v := newTestSchemaValidator(t)
+
+	var tests = []struct {
+		headers     map[string]string
+		body        string
+		expectedErr bool
+	}{
+		{map[string]string{"Content-Type": "application/json; charset=utf-8", "X-Schema-Version": "2"}, `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","type":"EOM::CompoundStory"}`, false},
+		{map[string]string{"Content-Type": "application/json", "X-Schema-Version": "2"}, `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`, true},
+		{map[string]string{"Content-Type": "application/json", "X-Schema-Version": "2"}, `not json`, true},
+		{map[string]string{"Content-Type": "application/json"}, `[]`, true},
+		{map[string]string{"Content-Type": "application/json"}, `{}`, false},
+		{map[string]string{"Content-Type": "application/json", "X-Schema-Version": "3"}, `[]`, false},
+		{map[string]string{"Content-Type": "application/xml"}, `<xml/>`, false},
+		{map[string]string{}, `anything`, false},
+		{map[string]string{"Content-Type": "application/json", "X-Schema-Version": "../2"}, `{}`, true},
+	}
+
+	for _, test := range tests {
+		err := v.validate(test.headers, test.body)
+		assert.Equal(t, test.expectedErr, err != nil, "%v %s", test.headers, test.body)
+	}
+
+	assert.Equal(t, int64(5), v.stats.Validated)
+	assert.Equal(t, int64(3), v.stats.Failed)
+	assert.Equal(t, int64(3), v.stats.Unvalidated)
+	assert.Equal(t, map[string]int64{"application/json/2.json": 2, "application/json/default.json": 1}, v.stats.FailuresBySchema)
+
+	w := httptest.NewRecorder()
+	v.statsHandler(w, httptest.NewRequest("GET", "http://example.com"+schemaValidationPath, nil))
+	stats := validationStats{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, int64(3), stats.Failed)
+}
+
+func TestDispatchDeadLettersInvalidMessages(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	recorder := &recordingBatchProducer{}
+	deadLetters := &recordingDeadLetterStore{}
+	bridge := BridgeApp{producerInstance: recorder, schemaValidator: newTestSchemaValidator(t), deadLetters: deadLetters}
+
+	msg := queueConsumer.Message{
+		Headers: map[string]string{"X-Request-Id": "tid_test", "Content-Type": "application/json", "X-Schema-Version": "2"},
+		Body:    `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`,
+	}
+	assert.Nil(t, bridge.dispatch(msg, nil))
+	assert.Empty(t, recorder.recorded(), "The invalid message isn't sent")
+	assert.Len(t, deadLetters.reasons, 1)
+	assert.Contains(t, deadLetters.reasons[0], "schema validation failed")
+}
+
+func TestNilSchemaValidator(t *testing.T) {
+	var v *schemaValidator
+	assert.NoError(t, v.validate(map[string]string{"Content-Type": "application/json"}, "not json"))
+}
+
+func TestNewSchemaValidatorMissingDir(t *testing.T) {
+	_, err := newSchemaValidator("/does/not/exist")
+	assert.Error(t, err)
+}
+
+func TestNewSchemaValidatorInvalidSchema(t *testing.T) {
+	dir, err := ioutil.TempDir("", "schemas")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "application", "json"), 0755))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "application", "json", "default.json"), []byte(`{"type":"object"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "application", "json", "2.json"), []byte(`{"type":12}`), 0644))
+
+	_, err = newSchemaValidator(dir)
+	assert.Error(t, err, "An invalid schema stops the bridge instead of failing the messages")
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+const (
+	shadowReportPath      = "/__shadow-report"
+	maxRecordedMismatches = 50
+)
+
+// shadowProducer sends every message to the primary producer and, concurrently, to a shadow producer.
+// Only the primary result is returned to the caller, the shadow result is only compared and recorded.
+// At most maxInFlight shadow sends run at once, the messages arriving while they all run aren't shadowed,
+// and a shadow send taking longer than the timeout is recorded as failed.
+type shadowProducer struct {
+	primary queueProducer.MessageProducer
+	shadow  queueProducer.MessageProducer
+	report  *shadowReport
+	timeout time.Duration
+	// inFlight holds a slot for every shadow send until it returns, even after it timed out
+	inFlight chan struct{}
+	// recording tracks the comparisons not recorded yet
+	recording sync.WaitGroup
+}
+
+type sendResult struct {
+	Error     string  `json:"error,omitempty"`
+	LatencyMs float64 `json:"latencyMs"`
+}
+
+type shadowMismatch struct {
+	TransactionID string     `json:"transactionId"`
+	Time          time.Time  `json:"time"`
+	Reason        string     `json:"reason"`
+	Primary       sendResult `json:"primary"`
+	Shadow        sendResult `json:"shadow"`
+}
+
+// shadowReport summarises the divergence between the primary and the shadow destination
+type shadowReport struct {
+	sync.Mutex
+	latencyTolerance    time.Duration
+	Compared            int64            `json:"compared"`
+	Matched             int64            `json:"matched"`
+	PrimaryOnlyFailures int64            `json:"primaryOnlyFailures"`
+	ShadowOnlyFailures  int64            `json:"shadowOnlyFailures"`
+	BothFailed          int64            `json:"bothFailed"`
+	LatencyMismatches   int64            `json:"latencyMismatches"`
+	Skipped             int64            `json:"skipped"`
+	AvgPrimaryLatencyMs float64          `json:"avgPrimaryLatencyMs"`
+	AvgShadowLatencyMs  float64          `json:"avgShadowLatencyMs"`
+	MaxLatencyDeltaMs   float64          `json:"maxLatencyDeltaMs"`
+	RecentMismatches    []shadowMismatch `json:"recentMismatches"`
+}
+
+func newShadowProducer(primary queueProducer.MessageProducer, shadow queueProducer.MessageProducer, latencyTolerance time.Duration, timeout time.Duration, maxInFlight int) *shadowProducer {
+	return &shadowProducer{
+		primary:  primary,
+		shadow:   shadow,
+		report:   &shadowReport{latencyTolerance: latencyTolerance, RecentMismatches: []shadowMismatch{}},
+		timeout:  timeout,
+		inFlight: make(chan struct{}, maxInFlight),
+	}
+}
+
+func (s *shadowProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	tid := messageHeaders(message.Headers).Get("X-Request-Id")
+	select {
+	case s.inFlight <- struct{}{}:
+	default:
+		s.report.skip(tid)
+		return s.primary.SendMessage(uuid, message)
+	}
+
+	shadowMessage := queueProducer.Message{Headers: make(map[string]string, len(message.Headers)), Body: message.Body}
+	for k, v := range message.Headers {
+		shadowMessage.Headers[k] = v
+	}
+	shadowDone := make(chan sendResult, 1)
+	go func() {
+		defer func() { <-s.inFlight }()
+		shadowDone <- timedSend(s.shadow, uuid, shadowMessage)
+	}()
+
+	start := time.Now()
+	err := s.primary.SendMessage(uuid, message)
+	primary := newSendResult(err, time.Since(start))
+
+	s.recording.Add(1)
+	go func() {
+		defer s.recording.Done()
+		timeout := time.NewTimer(s.timeout)
+		defer timeout.Stop()
+		select {
+		case shadow := <-shadowDone:
+			s.report.record(tid, primary, shadow)
+		case <-timeout.C:
+			s.report.record(tid, primary, newSendResult(errors.New("timed out after "+s.timeout.String()), time.Since(start)))
+		}
+	}()
+	return err
+}
+
+// ConnectivityCheck only reflects the primary destination, the shadow must never make the bridge unhealthy.
+func (s *shadowProducer) ConnectivityCheck() (string, error) {
+	return s.primary.ConnectivityCheck()
+}
+
+// Close waits for the pending comparisons to be recorded, then closes both producers
+func (s *shadowProducer) Close() error {
+	s.recording.Wait()
+	var errs []error
+	for _, p := range []queueProducer.MessageProducer{s.primary, s.shadow} {
+		if closer, ok := p.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *shadowProducer) reportHandler(w http.ResponseWriter, r *http.Request) {
+	s.report.Lock()
+	defer s.report.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.report); err != nil {
+		logger.Errorf(nil, err, "Couldn't encode shadow report")
+	}
+}
+
+func timedSend(p queueProducer.MessageProducer, uuid string, message queueProducer.Message) sendResult {
+	start := time.Now()
+	err := p.SendMessage(uuid, message)
+	return newSendResult(err, time.Since(start))
+}
+
+func newSendResult(err error, latency time.Duration) sendResult {
+	result := sendResult{LatencyMs: float64(latency) / float64(time.Millisecond)}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// skip counts a message which wasn't shadowed because too many shadow sends were running
+func (r *shadowReport) skip(tid string) {
+	r.Lock()
+	defer r.Unlock()
+	r.Skipped++
+	logger.NewEntry(tid).Warn("Too many shadow sends in flight, the message isn't sent to the shadow destination")
+}
+
+func (r *shadowReport) record(tid string, primary sendResult, shadow sendResult) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.Compared++
+	n := float64(r.Compared)
+	r.AvgPrimaryLatencyMs += (primary.LatencyMs - r.AvgPrimaryLatencyMs) / n
+	r.AvgShadowLatencyMs += (shadow.LatencyMs - r.AvgShadowLatencyMs) / n
+
+	delta := shadow.LatencyMs - primary.LatencyMs
+	if delta < 0 {
+		delta = -delta
+	}
+	if delta > r.MaxLatencyDeltaMs {
+		r.MaxLatencyDeltaMs = delta
+	}
+
+	var reason string
+	switch {
+	case primary.Error != "" && shadow.Error != "":
+		r.BothFailed++
+		if primary.Error != shadow.Error {
+			reason = "both destinations failed with different errors"
+		}
+	case primary.Error != "":
+		r.PrimaryOnlyFailures++
+		reason = "only the primary destination failed"
+	case shadow.Error != "":
+		r.ShadowOnlyFailures++
+		reason = "only the shadow destination failed"
+	case delta > float64(r.latencyTolerance)/float64(time.Millisecond):
+		r.LatencyMismatches++
+		reason = "latency difference exceeds tolerance"
+	default:
+		r.Matched++
+	}
+
+	if reason == "" {
+		return
+	}
+	logger.NewEntry(tid).Warn("Shadow destination diverged from primary: " + reason)
+	r.RecentMismatches = append(r.RecentMismatches, shadowMismatch{
+		TransactionID: tid,
+		Time:          time.Now().UTC(),
+		Reason:        reason,
+		Primary:       primary,
+		Shadow:        shadow,
+	})
+	if len(r.RecentMismatches) > maxRecordedMismatches {
+		r.RecentMismatches = r.RecentMismatches[len(r.RecentMismatches)-maxRecordedMismatches:]
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingProducer struct {
+	err error
+}
+
+func (p *failingProducer) SendMessage(string, queueProducer.Message) error {
+	return p.err
+}
+
+func (p *failingProducer) ConnectivityCheck() (string, error) {
+	return "", p.err
+}
+
+// blockingProducer doesn't return from SendMessage until it is released
+type blockingProducer struct {
+	release chan struct{}
+}
+
+func (p *blockingProducer) SendMessage(string, queueProducer.Message) error {
+	<-p.release
+	return nil
+}
+
+func (p *blockingProducer) ConnectivityCheck() (string, error) {
+	return "", nil
+}
+
+func TestShadowProducerReturnsPrimaryResult(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	msg := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: "{}"}
+
+	p := newShadowProducer(&mockProducerInstance{isConnectionHealthy: true}, &failingProducer{errors.New("shadow down")}, time.Second, time.Second, 10)
+	assert.NoError(t, p.SendMessage("", msg))
+	_, err := p.ConnectivityCheck()
+	assert.NoError(t, err, "The shadow must not affect the connectivity check")
+	assert.NoError(t, p.Close())
+	assert.Equal(t, int64(1), p.report.ShadowOnlyFailures)
+	assert.Equal(t, "shadow down", p.report.RecentMismatches[0].Shadow.Error)
+
+	p = newShadowProducer(&failingProducer{errors.New("primary down")}, &mockProducerInstance{isConnectionHealthy: true}, time.Second, time.Second, 10)
+	assert.EqualError(t, p.SendMessage("", msg), "primary down")
+	assert.NoError(t, p.Close())
+	assert.Equal(t, int64(1), p.report.PrimaryOnlyFailures)
+	assert.Equal(t, "tid_test", p.report.RecentMismatches[0].TransactionID)
+}
+
+func TestShadowProducerBoundsShadowSends(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	msg := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: "{}"}
+	shadow := &blockingProducer{release: make(chan struct{})}
+
+	p := newShadowProducer(&mockProducerInstance{isConnectionHealthy: true}, shadow, time.Second, 10*time.Millisecond, 1)
+	assert.NoError(t, p.SendMessage("", msg))
+	assert.NoError(t, p.SendMessage("", msg), "The primary is sent while the shadow is busy")
+	assert.NoError(t, p.Close(), "Close doesn't wait for a timed out shadow send")
+	assert.Equal(t, int64(1), p.report.ShadowOnlyFailures)
+	assert.Equal(t, "timed out after 10ms", p.report.RecentMismatches[0].Shadow.Error)
+	assert.Equal(t, int64(1), p.report.Skipped)
+
+	close(shadow.release)
+	assert.Eventually(t, func() bool { return len(p.inFlight) == 0 }, time.Second, time.Millisecond, "The slot is released once the shadow send returns")
+}
+
+func TestShadowReportRecord(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	var tests = []struct {
+		primary  sendResult
+		shadow   sendResult
+		expected *shadowReport
+	}{
+		{sendResult{LatencyMs: 10}, sendResult{LatencyMs: 20}, &shadowReport{Compared: 1, Matched: 1}},
+		{sendResult{Error: "boom", LatencyMs: 10}, sendResult{LatencyMs: 20}, &shadowReport{Compared: 1, PrimaryOnlyFailures: 1}},
+		{sendResult{LatencyMs: 10}, sendResult{Error: "boom", LatencyMs: 20}, &shadowReport{Compared: 1, ShadowOnlyFailures: 1}},
+		{sendResult{Error: "boom", LatencyMs: 10}, sendResult{Error: "boom", LatencyMs: 20}, &shadowReport{Compared: 1, BothFailed: 1}},
+		{sendResult{Error: "boom", LatencyMs: 10}, sendResult{Error: "bang", LatencyMs: 20}, &shadowReport{Compared: 1, BothFailed: 1}},
+		{sendResult{LatencyMs: 10}, sendResult{LatencyMs: 2000}, &shadowReport{Compared: 1, LatencyMismatches: 1}},
+	}
+
+	for _, test := range tests {
+		report := &shadowReport{latencyTolerance: time.Second}
+		report.record("tid_test", test.primary, test.shadow)
+
+		assert.Equal(t, test.expected.Compared, report.Compared)
+		assert.Equal(t, test.expected.Matched, report.Matched)
+		assert.Equal(t, test.expected.PrimaryOnlyFailures, report.PrimaryOnlyFailures)
+		assert.Equal(t, test.expected.ShadowOnlyFailures, report.ShadowOnlyFailures)
+		assert.Equal(t, test.expected.BothFailed, report.BothFailed)
+		assert.Equal(t, test.expected.LatencyMismatches, report.LatencyMismatches)
+		if test.expected.Matched == 1 || (test.expected.BothFailed == 1 && test.primary.Error == test.shadow.Error) {
+			assert.Empty(t, report.RecentMismatches)
+		} else {
+			assert.Len(t, report.RecentMismatches, 1)
+		}
+	}
+}
+
+func TestShadowReportHandler(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	p := newShadowProducer(&mockProducerInstance{isConnectionHealthy: true}, &mockProducerInstance{isConnectionHealthy: true}, time.Second, time.Second, 10)
+	for i := 0; i < maxRecordedMismatches+5; i++ {
+		p.report.record("tid_test", sendResult{}, sendResult{Error: "boom"})
+	}
+
+	w := httptest.NewRecorder()
+	p.reportHandler(w, httptest.NewRequest("GET", "http://example.com"+shadowReportPath, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	report := shadowReport{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, int64(maxRecordedMismatches+5), report.ShadowOnlyFailures)
+	assert.Len(t, report.RecentMismatches, maxRecordedMismatches)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"errors"
+	"regexp"
+
+	"github.com/dchest/uniuri"
+)
+
+const (
+	tidPolicyAccept     = "accept"
+	tidPolicyRegenerate = "regenerate"
+	tidPolicyReject     = "reject"
+
+	defaultOriginalTIDHeader = "X-Original-Request-Id"
+)
+
+// tidValidation decides what happens to messages whose X-Request-Id doesn't fully match the regexp:
+// accept forwards them as they are, regenerate replaces the transaction id and keeps the original one
+// in another header, and reject dead-letters them. A nil tidValidation accepts every transaction id.
+type tidValidation struct {
+	valid          *regexp.Regexp
+	policy         string
+	originalHeader string
+}
+
+func newTIDValidation(policy string, validRegexp string, originalHeader string) (*tidValidation, error) {
+	switch policy {
+	case "", tidPolicyAccept:
+		return nil, nil
+	case tidPolicyRegenerate, tidPolicyReject:
+	default:
+		return nil, errors.New("unknown transaction id policy " + policy)
+	}
+
+	// anchored, so that a transaction id is valid when the whole of it matches any of the alternatives
+	valid, err := regexp.Compile("^(?:" + validRegexp + ")$")
+	if err != nil {
+		return nil, err
+	}
+	if originalHeader == "" {
+		originalHeader = defaultOriginalTIDHeader
+	}
+	return &tidValidation{valid: valid, policy: policy, originalHeader: originalHeader}, nil
+}
+
+func (v *tidValidation) isValid(tid string) bool {
+	return v.valid.MatchString(tid)
+}
+
+// enforce returns the transaction id to forward the message with, or an error when the message must be rejected
+func (v *tidValidation) enforce(headers map[string]string, tid string) (string, error) {
+	if v == nil || v.isValid(tid) {
+		return tid, nil
+	}
+	if v.policy == tidPolicyReject {
+		return "", errors.New("invalid transaction id " + tid)
+	}
+	messageHeaders(headers).Set(v.originalHeader, tid)
+	return newBridgeTID(), nil
+}
+
+// newBridgeTID generates a transaction id for the messages the bridge can't take one from
+func newBridgeTID() string {
+	return "tid_" + uniuri.NewLen(10) + "_kafka_bridge"
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Financial-Times/go-logger"
+	queueConsumer "github.com/Financial-Times/message-queue-gonsumer/consumer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTIDValidation(t *testing.T) {
+	var tests = []struct {
+		policy      string
+		tid         string
+		expectedTID string
+		regenerated bool
+		rejected    bool
+	}{
+		{tidPolicyRegenerate, "tid_ABCDe12345", "tid_ABCDe12345", false, false},
+		{tidPolicyRegenerate, "SYNTHETIC-REQ-MON_ABCDe12345", "SYNTHETIC-REQ-MON_ABCDe12345", false, false},
+		{tidPolicyRegenerate, "tid_ABCDe1234%", "", true, false},
+		{tidPolicyRegenerate, "<script>tid_ABCDe12345", "", true, false},
+		{tidPolicyReject, "tid_ABCDe12345", "tid_ABCDe12345", false, false},
+		{tidPolicyReject, "ABCDE12345", "", false, true},
+		{tidPolicyAccept, "ABCDE12345", "ABCDE12345", false, false},
+	}
+
+	for _, test := range tests {
+		validation, err := newTIDValidation(test.policy, tidValidRegexp, "")
+		assert.NoError(t, err)
+
+		headers := map[string]string{"X-Request-Id": test.tid}
+		tid, err := validation.enforce(headers, test.tid)
+		switch {
+		case test.rejected:
+			assert.Error(t, err, test.tid)
+		case test.regenerated:
+			assert.NoError(t, err, test.tid)
+			assert.True(t, strings.HasPrefix(tid, "tid_") && strings.HasSuffix(tid, "_kafka_bridge"), tid)
+			assert.Equal(t, test.tid, headers[defaultOriginalTIDHeader])
+		default:
+			assert.NoError(t, err, test.tid)
+			assert.Equal(t, test.expectedTID, tid)
+			assert.NotContains(t, headers, defaultOriginalTIDHeader)
+		}
+	}
+}
+
+func TestTIDValidationMatchesWholeTID(t *testing.T) {
+	// the leftmost alternative matches a prefix of the transaction id, the second one the whole of it
+	validation, err := newTIDValidation(tidPolicyReject, "tid|tid_[a-z]+", "")
+	assert.NoError(t, err)
+	assert.True(t, validation.isValid("tid_abc"))
+	assert.True(t, validation.isValid("tid"))
+	assert.False(t, validation.isValid("tid_ABC"))
+	assert.False(t, validation.isValid("xtid"))
+}
+
+func TestDispatchDeadLettersRejectedTIDs(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	validation, err := newTIDValidation(tidPolicyReject, tidValidRegexp, "")
+	assert.NoError(t, err)
+	recorder := &recordingBatchProducer{}
+	deadLetters := &recordingDeadLetterStore{}
+	bridge := BridgeApp{producerInstance: recorder, tidValidation: validation, deadLetters: deadLetters}
+
+	assert.Nil(t, bridge.dispatch(queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "ABCDE12345"}, Body: `{}`}, nil))
+	assert.Empty(t, recorder.recorded(), "The message with an invalid transaction id isn't sent")
+	assert.Equal(t, []string{"invalid transaction id ABCDE12345"}, deadLetters.reasons)
+
+	bridge.dispatch(queueConsumer.Message{Headers: map[string]string{"X-Request-Id": "tid_ABCDe12345"}, Body: `{}`}, nil).wait()
+	assert.Equal(t, [][]string{{"{}"}}, recorder.recorded(), "The message with a valid transaction id is sent")
+	assert.Len(t, deadLetters.reasons, 1)
+}
+
+func TestNewTIDValidationInvalid(t *testing.T) {
+	_, err := newTIDValidation("ignore", tidValidRegexp, "")
+	assert.Error(t, err)
+	_, err = newTIDValidation(tidPolicyReject, "tid_(", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	timestampPreserve = "preserve"
+	timestampRewrite  = "rewrite"
+
+	messageTimestampHeader         = "Message-Timestamp"
+	originalMessageTimestampHeader = "X-Original-Message-Timestamp"
+	receivedAtHeader               = "X-Bridge-Received-At"
+	forwardedAtHeader              = "X-Bridge-Forwarded-At"
+
+	// messageTimestampFormat is the format of the Message-Timestamp header of UPP messages
+	messageTimestampFormat = "2006-01-02T15:04:05.000Z"
+)
+
+// timestampPolicy decides the Message-Timestamp of the forwarded messages: preserve keeps the consumed one,
+// rewrite replaces it with the forwarding time and keeps the original in another header. Either way messages
+// without a Message-Timestamp get the time they were received, so that it is never empty downstream.
+// When secondary is set, the received-at and forwarded-at times are added as well.
+type timestampPolicy struct {
+	rewrite   bool
+	secondary bool
+}
+
+func newTimestampPolicy(policy string, secondary bool) (timestampPolicy, error) {
+	switch policy {
+	case "", timestampPreserve:
+		return timestampPolicy{secondary: secondary}, nil
+	case timestampRewrite:
+		return timestampPolicy{rewrite: true, secondary: secondary}, nil
+	default:
+		return timestampPolicy{}, errors.New("unknown timestamp policy " + policy)
+	}
+}
+
+// addedHeaders returns the headers added besides Message-Timestamp
+func (p timestampPolicy) addedHeaders() []string {
+	var headers []string
+	if p.rewrite {
+		headers = append(headers, originalMessageTimestampHeader)
+	}
+	if p.secondary {
+		headers = append(headers, receivedAtHeader, forwardedAtHeader)
+	}
+	return headers
+}
+
+func (p timestampPolicy) stamp(headers map[string]string, receivedAt time.Time, forwardedAt time.Time) {
+	h := messageHeaders(headers)
+	original := h.Get(messageTimestampHeader)
+	switch {
+	case original == "":
+		h.Set(messageTimestampHeader, formatMessageTimestamp(receivedAt))
+	case p.rewrite:
+		h.Set(originalMessageTimestampHeader, original)
+		h.Set(messageTimestampHeader, formatMessageTimestamp(forwardedAt))
+	}
+	if p.secondary {
+		h.Set(receivedAtHeader, formatMessageTimestamp(receivedAt))
+		h.Set(forwardedAtHeader, formatMessageTimestamp(forwardedAt))
+	}
+}
+
+func formatMessageTimestamp(t time.Time) string {
+	return t.UTC().Format(messageTimestampFormat)
+}
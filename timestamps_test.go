@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimestampPolicy(t *testing.T) {
+	receivedAt := time.Date(2015, 7, 6, 7, 3, 10, 12000000, time.UTC)
+	forwardedAt := receivedAt.Add(250 * time.Millisecond)
+
+	var tests = []struct {
+		name      string
+		policy    string
+		secondary bool
+		headers   map[string]string
+		expected  map[string]string
+	}{
+		{
+			"preserve",
+			timestampPreserve,
+			false,
+			map[string]string{"Message-Timestamp": "2015-07-06T07:03:09.362Z"},
+			map[string]string{"Message-Timestamp": "2015-07-06T07:03:09.362Z"},
+		},
+		{
+			"generate missing",
+			timestampPreserve,
+			false,
+			map[string]string{"Message-Timestamp": ""},
+			map[string]string{"Message-Timestamp": "2015-07-06T07:03:10.012Z"},
+		},
+		{
+			"rewrite",
+			timestampRewrite,
+			false,
+			map[string]string{"message-timestamp": "2015-07-06T07:03:09.362Z"},
+			map[string]string{"Message-Timestamp": "2015-07-06T07:03:10.262Z", "X-Original-Message-Timestamp": "2015-07-06T07:03:09.362Z"},
+		},
+		{
+			"secondary",
+			timestampPreserve,
+			true,
+			map[string]string{"Message-Timestamp": "2015-07-06T07:03:09.362Z"},
+			map[string]string{
+				"Message-Timestamp":     "2015-07-06T07:03:09.362Z",
+				"X-Bridge-Received-At":  "2015-07-06T07:03:10.012Z",
+				"X-Bridge-Forwarded-At": "2015-07-06T07:03:10.262Z",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		policy, err := newTimestampPolicy(test.policy, test.secondary)
+		assert.NoError(t, err, test.name)
+		policy.stamp(test.headers, receivedAt, forwardedAt)
+		assert.Equal(t, test.expected, test.headers, test.name)
+	}
+
+	_, err := newTimestampPolicy("now", false)
+	assert.Error(t, err)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	headerPlaceholderPrefix = "header:"
+	bodyPlaceholderPrefix   = "body:"
+)
+
+// urlTemplate is a path with placeholders resolved from the message being sent:
+// {header:Message-Id} is taken from the headers, {body:payload.uuid} from a (dotted) JSON body field,
+// and a bare {uuid} is looked up in the headers first, then in the body.
+type urlTemplate struct {
+	literals     []string
+	placeholders []string
+}
+
+func parseURLTemplate(tmpl string) (*urlTemplate, error) {
+	t := &urlTemplate{}
+	rest := tmpl
+	for {
+		open := strings.Index(rest, "{")
+		if open < 0 {
+			if strings.Contains(rest, "}") {
+				return nil, fmt.Errorf("unexpected '}' in template '%s'", tmpl)
+			}
+			t.literals = append(t.literals, rest)
+			return t, nil
+		}
+		closing := strings.Index(rest[open:], "}")
+		if closing < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in template '%s'", tmpl)
+		}
+		literal, placeholder := rest[:open], rest[open+1:open+closing]
+		if strings.Contains(literal, "}") {
+			return nil, fmt.Errorf("unexpected '}' in template '%s'", tmpl)
+		}
+		if placeholder == "" || strings.Contains(placeholder, "{") {
+			return nil, fmt.Errorf("invalid placeholder in template '%s'", tmpl)
+		}
+		t.literals = append(t.literals, literal)
+		t.placeholders = append(t.placeholders, placeholder)
+		rest = rest[open+closing+1:]
+	}
+}
+
+// expand resolves the placeholders, escaping the values for use in a URL path
+func (t *urlTemplate) expand(headers map[string]string, body string) (string, error) {
+	return t.expandWith(headers, body, url.PathEscape)
+}
+
+// expandRaw resolves the placeholders without escaping the values, for keys which aren't URLs
+func (t *urlTemplate) expandRaw(headers map[string]string, body string) (string, error) {
+	return t.expandWith(headers, body, func(value string) string { return value })
+}
+
+func (t *urlTemplate) expandWith(headers map[string]string, body string, escape func(string) string) (string, error) {
+	var parsedBody interface{}
+	bodyParsed := false
+	bodyField := func(path string) (string, bool) {
+		if !bodyParsed {
+			bodyParsed = true
+			parsedBody, _ = decodeJSON([]byte(body))
+		}
+		return jsonFieldString(parsedBody, path)
+	}
+
+	var expanded strings.Builder
+	for i, literal := range t.literals {
+		expanded.WriteString(literal)
+		if i >= len(t.placeholders) {
+			break
+		}
+		placeholder := t.placeholders[i]
+
+		var value string
+		var found bool
+		switch {
+		case strings.HasPrefix(placeholder, headerPlaceholderPrefix):
+			value, found = messageHeaders(headers).Lookup(strings.TrimPrefix(placeholder, headerPlaceholderPrefix))
+		case strings.HasPrefix(placeholder, bodyPlaceholderPrefix):
+			value, found = bodyField(strings.TrimPrefix(placeholder, bodyPlaceholderPrefix))
+		default:
+			value, found = messageHeaders(headers).Lookup(placeholder)
+			if !found {
+				value, found = bodyField(placeholder)
+			}
+		}
+		if !found || value == "" {
+			return "", errors.New("couldn't resolve placeholder {" + placeholder + "}")
+		}
+		expanded.WriteString(escape(value))
+	}
+	return expanded.String(), nil
+}
+
+func (t *urlTemplate) String() string {
+	var s strings.Builder
+	for i, literal := range t.literals {
+		s.WriteString(literal)
+		if i < len(t.placeholders) {
+			s.WriteString("{" + t.placeholders[i] + "}")
+		}
+	}
+	return s.String()
+}
+
+// jsonFieldString returns the scalar value found at the dotted path
+func jsonFieldString(doc interface{}, path string) (string, bool) {
+	value, found := getJSONPath(doc, path)
+	if !found {
+		return "", false
+	}
+	switch v := value.(type) {
+	case string:
+		return v, true
+	case json.Number:
+		return v.String(), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestURLTemplateExpand(t *testing.T) {
+	headers := map[string]string{
+		"Message-Id":   "fc429b46-2500-4fe7-88bb-fd507fbaf00c",
+		"Message-Type": "cms-content-published",
+		"uuid":         "from-header",
+	}
+	body := `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c","payload":{"title":"a/b c","version":12}}`
+
+	var tests = []struct {
+		template    string
+		expected    string
+		expectedErr bool
+	}{
+		{"/notify", "/notify", false},
+		{"/content/{body:uuid}", "/content/7543220a-2389-11e5-bd83-71cb60e8f08c", false},
+		{"/content/{uuid}", "/content/from-header", false},
+		{"/{header:Message-Type}/{Message-Id}", "/cms-content-published/fc429b46-2500-4fe7-88bb-fd507fbaf00c", false},
+		{"/titles/{body:payload.title}/v{body:payload.version}", "/titles/a%2Fb%20c/v12", false},
+		{"/content/{body:missing}", "", true},
+		{"/content/{header:uuid}/{body:payload}", "", true},
+	}
+
+	for _, test := range tests {
+		tmpl, err := parseURLTemplate(test.template)
+		assert.NoError(t, err, test.template)
+		assert.Equal(t, test.template, tmpl.String())
+
+		actual, err := tmpl.expand(headers, body)
+		if test.expectedErr {
+			assert.Error(t, err, test.template)
+			continue
+		}
+		assert.NoError(t, err, test.template)
+		assert.Equal(t, test.expected, actual)
+	}
+}
+
+func TestParseURLTemplateInvalid(t *testing.T) {
+	for _, tmpl := range []string{"/content/{uuid", "/content/uuid}", "/content/{}", "/content/{a{b}}"} {
+		_, err := parseURLTemplate(tmpl)
+		assert.Error(t, err, tmpl)
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+)
+
+const (
+	webhookSignatureHeader = "X-Bridge-Signature"
+	webhookTimestampHeader = "X-Bridge-Timestamp"
+	webhookSignaturePrefix = "sha256="
+
+	defaultWebhookMaxAttempts = 3
+	defaultWebhookBackoff     = 500 * time.Millisecond
+	defaultWebhookMaxBackoff  = 10 * time.Second
+)
+
+// webhookConfig holds the settings specific to the webhook producer: a JSON list of endpoints,
+// or the secret of the producer address when no list is given
+type webhookConfig struct {
+	endpoints string
+	secret    string
+}
+
+// webhookEndpoint is a URL the messages are pushed to, with its own secret and retry policy.
+// The secret can be read from the environment variable named by SecretEnv, to keep it out of the endpoint list.
+type webhookEndpoint struct {
+	URL       string `json:"url"`
+	Secret    string `json:"secret"`
+	SecretEnv string `json:"secretEnv"`
+	// MaxAttempts is the number of attempts for every message, including the first one
+	MaxAttempts int `json:"maxAttempts"`
+	// Backoff is the wait before the first retry, doubled for every following one up to MaxBackoff
+	Backoff    string `json:"backoff"`
+	MaxBackoff string `json:"maxBackoff"`
+
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+// webhookEnvelope is the JSON document POSTed for every message
+type webhookEnvelope struct {
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// parseWebhookEndpoints parses a JSON list of endpoints. Without one, the messages are pushed to the
+// producer address, signed with the default secret.
+func parseWebhookEndpoints(spec string, defaultURL string, defaultSecret string) ([]*webhookEndpoint, error) {
+	var endpoints []*webhookEndpoint
+	if strings.TrimSpace(spec) == "" {
+		endpoints = []*webhookEndpoint{{URL: defaultURL, Secret: defaultSecret}}
+	} else if err := json.Unmarshal([]byte(spec), &endpoints); err != nil {
+		return nil, err
+	}
+	if len(endpoints) == 0 {
+		return nil, errors.New("no webhook endpoint is configured")
+	}
+
+	for _, endpoint := range endpoints {
+		if endpoint.URL == "" {
+			return nil, errors.New("a webhook endpoint has no url")
+		}
+		if endpoint.SecretEnv != "" {
+			endpoint.Secret = os.Getenv(endpoint.SecretEnv)
+		}
+		if endpoint.Secret == "" {
+			return nil, fmt.Errorf("the webhook endpoint %s has no secret", endpoint.URL)
+		}
+		if endpoint.MaxAttempts == 0 {
+			endpoint.MaxAttempts = defaultWebhookMaxAttempts
+		}
+		if endpoint.MaxAttempts < 1 {
+			return nil, fmt.Errorf("the webhook endpoint %s needs at least one attempt", endpoint.URL)
+		}
+		var err error
+		if endpoint.backoff, err = parseDurationOr(endpoint.Backoff, defaultWebhookBackoff); err != nil {
+			return nil, fmt.Errorf("the webhook endpoint %s has an invalid backoff: %v", endpoint.URL, err)
+		}
+		if endpoint.maxBackoff, err = parseDurationOr(endpoint.MaxBackoff, defaultWebhookMaxBackoff); err != nil {
+			return nil, fmt.Errorf("the webhook endpoint %s has an invalid max backoff: %v", endpoint.URL, err)
+		}
+	}
+	return endpoints, nil
+}
+
+func parseDurationOr(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	return time.ParseDuration(value)
+}
+
+// webhookMessageProducer POSTs every message as a JSON envelope to each endpoint. Requests are signed with
+// an HMAC-SHA256 of "<timestamp>.<payload>" using the endpoint secret, so that receivers can check both
+// the origin and, by rejecting old timestamps, replays. Network errors, 429 and 5xx responses are retried.
+type webhookMessageProducer struct {
+	endpoints []*webhookEndpoint
+	client    plainHttpClient
+	now       func() time.Time
+	sleep     func(time.Duration)
+}
+
+func newWebhookMessageProducer(endpoints []*webhookEndpoint) *webhookMessageProducer {
+	return &webhookMessageProducer{
+		endpoints: endpoints,
+		client: &http.Client{
+			Timeout: 60 * time.Second,
+			Transport: &http.Transport{
+				MaxIdleConnsPerHost: 100,
+				Dial: (&net.Dialer{
+					KeepAlive: 30 * time.Second,
+				}).Dial,
+			}},
+		now:   time.Now,
+		sleep: time.Sleep,
+	}
+}
+
+// SendMessage delivers the message to every endpoint concurrently, it fails if any of them fails
+func (p *webhookMessageProducer) SendMessage(uuid string, message queueProducer.Message) error {
+	tid := messageHeaders(message.Headers).Get("X-Request-Id")
+	payload, err := json.Marshal(webhookEnvelope{Headers: message.Headers, Body: message.Body})
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, len(p.endpoints))
+	var wg sync.WaitGroup
+	for i, endpoint := range p.endpoints {
+		wg.Add(1)
+		go func(i int, endpoint *webhookEndpoint) {
+			defer wg.Done()
+			errs[i] = p.deliver(endpoint, tid, payload)
+		}(i, endpoint)
+	}
+	wg.Wait()
+
+	var failures []string
+	for _, err := range errs {
+		if err != nil {
+			failures = append(failures, err.Error())
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("Forwarding message with tid: %s is not successful: %s", tid, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+// deliver posts the payload to the endpoint, retrying with an exponential backoff
+func (p *webhookMessageProducer) deliver(endpoint *webhookEndpoint, tid string, payload []byte) error {
+	backoff := endpoint.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := p.post(endpoint, tid, payload)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= endpoint.MaxAttempts {
+			return fmt.Errorf("%s failed after %d attempt(s): %v", endpoint.URL, attempt, err)
+		}
+		logger.NewEntry(tid).Warn("Webhook delivery to " + endpoint.URL + " failed, retrying in " + backoff.String() + ": " + err.Error())
+		p.sleep(backoff)
+		if backoff *= 2; backoff > endpoint.maxBackoff {
+			backoff = endpoint.maxBackoff
+		}
+	}
+}
+
+// post makes a single attempt, the signature is computed again so that its timestamp is the one of the attempt
+func (p *webhookMessageProducer) post(endpoint *webhookEndpoint, tid string, payload []byte) (bool, error) {
+	req, err := http.NewRequest("POST", endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return false, fmt.Errorf("Error creating new request: %v", err)
+	}
+	timestamp := strconv.FormatInt(p.now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Request-Id", tid)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, webhookSignature(endpoint.Secret, timestamp, payload))
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("Status: %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("Status: %d", resp.StatusCode)
+	}
+}
+
+// ConnectivityCheck reaches every endpoint with a HEAD request. Webhooks usually only accept POST,
+// so any response short of a server error counts as reachable.
+func (p *webhookMessageProducer) ConnectivityCheck() (string, error) {
+	for _, endpoint := range p.endpoints {
+		req, err := http.NewRequest("HEAD", endpoint.URL, nil)
+		if err != nil {
+			return "Forwarding messages is broken. Error creating the webhook healthcheck request", err
+		}
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return "Forwarding messages is broken. Couldn't reach webhook " + endpoint.URL, err
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode >= 500 {
+			return "Forwarding messages is broken. Webhook " + endpoint.URL + " is failing", fmt.Errorf("Status: %d", resp.StatusCode)
+		}
+	}
+	return "The webhook endpoints are reachable", nil
+}
+
+func webhookSignature(secret string, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return webhookSignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
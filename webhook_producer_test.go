@@ -0,0 +1,169 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Financial-Times/go-logger"
+	queueProducer "github.com/Financial-Times/message-queue-go-producer/producer"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyWebhook checks a request the way a webhook consumer would, rejecting signatures older than the tolerance
+func verifyWebhook(r *http.Request, secret string, now time.Time, tolerance time.Duration) (webhookEnvelope, bool) {
+	payload, _ := ioutil.ReadAll(r.Body)
+	timestamp := r.Header.Get(webhookTimestampHeader)
+	signedAt, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || now.Sub(time.Unix(signedAt, 0)) > tolerance {
+		return webhookEnvelope{}, false
+	}
+	if !hmac.Equal([]byte(r.Header.Get(webhookSignatureHeader)), []byte(webhookSignature(secret, timestamp, payload))) {
+		return webhookEnvelope{}, false
+	}
+	envelope := webhookEnvelope{}
+	return envelope, json.Unmarshal(payload, &envelope) == nil
+}
+
+func TestWebhookSendMessage(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	now := time.Unix(1792371723, 0)
+	var lock sync.Mutex
+	received := map[string]webhookEnvelope{}
+	handler := func(name string, secret string) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			envelope, valid := verifyWebhook(r, secret, now, 5*time.Minute)
+			assert.True(t, valid, name)
+			assert.Equal(t, "tid_test", r.Header.Get("X-Request-Id"))
+			lock.Lock()
+			received[name] = envelope
+			lock.Unlock()
+		}
+	}
+	first := httptest.NewServer(handler("first", "first-secret"))
+	defer first.Close()
+	second := httptest.NewServer(handler("second", "second-secret"))
+	defer second.Close()
+
+	os.Setenv("TEST_WEBHOOK_SECRET", "second-secret")
+	defer os.Unsetenv("TEST_WEBHOOK_SECRET")
+	endpoints, err := parseWebhookEndpoints(`[{"url":"`+first.URL+`","secret":"first-secret"},{"url":"`+second.URL+`","secretEnv":"TEST_WEBHOOK_SECRET"}]`, "", "")
+	assert.NoError(t, err)
+	p := newWebhookMessageProducer(endpoints)
+	p.now = func() time.Time { return now }
+
+	msg := queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{"uuid":"7543220a-2389-11e5-bd83-71cb60e8f08c"}`}
+	assert.NoError(t, p.SendMessage("", msg))
+	assert.Equal(t, map[string]webhookEnvelope{
+		"first":  {Headers: msg.Headers, Body: msg.Body},
+		"second": {Headers: msg.Headers, Body: msg.Body},
+	}, received)
+}
+
+func TestWebhookSignatureReplay(t *testing.T) {
+	signedAt := time.Unix(1792371723, 0)
+	payload := []byte(`{"headers":{},"body":"{}"}`)
+	timestamp := strconv.FormatInt(signedAt.Unix(), 10)
+
+	req := httptest.NewRequest("POST", "/hook", nil)
+	req.Header.Set(webhookTimestampHeader, timestamp)
+	req.Header.Set(webhookSignatureHeader, webhookSignature("secret", timestamp, payload))
+
+	var tests = []struct {
+		name     string
+		secret   string
+		now      time.Time
+		expected bool
+	}{
+		{"fresh", "secret", signedAt.Add(time.Minute), true},
+		{"replayed", "secret", signedAt.Add(time.Hour), false},
+		{"wrong secret", "other", signedAt, false},
+	}
+	for _, test := range tests {
+		req.Body = ioutil.NopCloser(bytes.NewReader(payload))
+		_, valid := verifyWebhook(req, test.secret, test.now, 5*time.Minute)
+		assert.Equal(t, test.expected, valid, test.name)
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	logger.InitDefaultLogger("kafka-bridge")
+	var tests = []struct {
+		name             string
+		statuses         []int
+		maxAttempts      int
+		expectedAttempts int
+		expectedError    bool
+	}{
+		{"recovers", []int{503, 429, 200}, 3, 3, false},
+		{"gives up", []int{500, 500, 500, 200}, 3, 3, true},
+		{"client error", []int{400, 200}, 3, 1, true},
+	}
+
+	for _, test := range tests {
+		attempts := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.statuses[attempts])
+			attempts++
+		}))
+		endpoints, err := parseWebhookEndpoints(`[{"url":"`+server.URL+`","secret":"s","maxAttempts":`+strconv.Itoa(test.maxAttempts)+`,"backoff":"1s","maxBackoff":"1500ms"}]`, "", "")
+		assert.NoError(t, err, test.name)
+		p := newWebhookMessageProducer(endpoints)
+		var waits []time.Duration
+		p.sleep = func(d time.Duration) { waits = append(waits, d) }
+
+		err = p.SendMessage("", queueProducer.Message{Headers: map[string]string{"X-Request-Id": "tid_test"}, Body: `{}`})
+		assert.Equal(t, test.expectedError, err != nil, test.name)
+		assert.Equal(t, test.expectedAttempts, attempts, test.name)
+		if test.expectedAttempts == 3 {
+			assert.Equal(t, []time.Duration{time.Second, 1500 * time.Millisecond}, waits, test.name)
+		}
+		server.Close()
+	}
+}
+
+func TestParseWebhookEndpoints(t *testing.T) {
+	endpoints, err := parseWebhookEndpoints("", "http://localhost:8080/hook", "secret")
+	assert.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, defaultWebhookMaxAttempts, endpoints[0].MaxAttempts)
+	assert.Equal(t, defaultWebhookBackoff, endpoints[0].backoff)
+
+	var invalid = []string{
+		`[]`,
+		`[{"secret":"s"}]`,
+		`[{"url":"http://localhost/hook"}]`,
+		`[{"url":"http://localhost/hook","secret":"s","maxAttempts":-1}]`,
+		`[{"url":"http://localhost/hook","secret":"s","backoff":"soon"}]`,
+		`{"url":"http://localhost/hook"}`,
+	}
+	for _, spec := range invalid {
+		_, err := parseWebhookEndpoints(spec, "", "")
+		assert.Error(t, err, spec)
+	}
+	_, err = parseWebhookEndpoints("", "http://localhost:8080/hook", "")
+	assert.Error(t, err, "A secret is required")
+}
+
+func TestWebhookConnectivityCheck(t *testing.T) {
+	status := http.StatusMethodNotAllowed
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	p := newWebhookMessageProducer([]*webhookEndpoint{{URL: server.URL, Secret: "s"}})
+	_, err := p.ConnectivityCheck()
+	assert.NoError(t, err, "Endpoints which only accept POST are reachable")
+	status = http.StatusBadGateway
+	_, err = p.ConnectivityCheck()
+	assert.Error(t, err)
+}